@@ -2,6 +2,7 @@ package xmlwriter
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 )
 
@@ -60,48 +61,295 @@ func (a Attr) Float32(v float32) Attr {
 func (a Attr) Float64(v float64) Attr { a.Value = strconv.FormatFloat(v, 'g', -1, 64); return a }
 
 func (a Attr) write(w *Writer) error {
+	return w.writeAttr(a, nil, false)
+}
+
+// writeAttr writes an attribute. If value is not nil, it is used as the
+// attribute's value instead of a.Value, and is escaped if escape is true.
+// Values which are known not to need escaping, like the output of strconv
+// used by WriteAttrInt and friends, are written as they are.
+func (w *Writer) writeAttr(a Attr, value []byte, escape bool) error {
+	if err := w.checkContext(); err != nil {
+		return err
+	}
 	if w.Enforce {
 		if err := w.checkParent(noNodeFlag | elemNodeFlag); err != nil {
 			return err
 		}
 	}
 
-	if w.Indenter != nil {
-		if err := w.writeIndent(Event{StateOpen, AttrNode, 0}); err != nil {
-			return err
-		}
-	}
-
 	name := a.Name
 	if a.Prefix != "" {
 		name = a.Prefix + ":" + name
 	}
+	if w.current >= 0 && w.limits.MaxAttrs > 0 && w.nodes[w.current].attrs >= w.limits.MaxAttrs {
+		return &LimitError{Limit: "MaxAttrs", Max: int64(w.limits.MaxAttrs)}
+	}
+	if w.Enforce {
+		if err := w.printer.names.check(name); err != nil {
+			return err
+		}
+	}
 
+	declare := false
 	if a.URI != "" && w.current >= 0 {
-		ns := ns{prefix: a.Prefix, uri: a.URI}
-		found := false
-		fail := false
+		declare = true
 		for _, existing := range w.nodes[w.current].elem.namespaces {
-			if ns.prefix == existing.prefix {
-				found = true
-				if ns.uri != existing.uri {
-					fail = true
+			if a.Prefix == existing.prefix {
+				if a.URI != existing.uri {
+					return fmt.Errorf("uri already exists for ns prefix %s", a.Prefix)
 				}
+				declare = false
 				break
 			}
 		}
-		if fail {
-			return fmt.Errorf("uri already exists for ns prefix %s", a.Prefix)
-		} else if !found {
-			w.nodes[w.current].elem.namespaces = append(w.nodes[w.current].elem.namespaces, ns)
+	}
+
+	// the attribute is valid, so it counts from here on:
+	if declare {
+		w.nodes[w.current].elem.namespaces = append(w.nodes[w.current].elem.namespaces, ns{prefix: a.Prefix, uri: a.URI})
+	}
+	if w.current >= 0 {
+		w.nodes[w.current].attrs++
+	}
+	w.stats.Attrs++
+
+	if w.current >= 0 && w.nodes[w.current].kind == ElemNode &&
+		w.nodes[w.current].state == StateOpen && w.bufferAttrs() {
+
+		if a.URI == "" && a.Prefix != "" {
+			a.URI = w.lookupNS(a.Prefix)
+		}
+		if value != nil {
+			a.Value = string(value)
 		}
+		w.attrs = append(w.attrs, a)
+		return nil
 	}
 
-	if err := w.printer.printAttr(name, a.Value, w.Enforce); err != nil {
+	return w.printAttr(a, value, escape, name)
+}
+
+// printAttr writes an attribute to the printer, surrounded by the indenter
+// events for the attribute. name is the attribute's qualified name, which
+// has already been checked. value and escape are as for writeAttr.
+func (w *Writer) printAttr(a Attr, value []byte, escape bool, name string) error {
+	if value != nil && (w.Indenter != nil || len(w.listeners) > 0) {
+		// the events need the value:
+		a.Value = string(value)
+	}
+	if w.Indenter != nil {
+		if err := w.writeIndent(w.attrEvent(StateOpen, a)); err != nil {
+			return err
+		}
+	}
+	start := w.printer.Offset()
+	if len(w.listeners) > 0 {
+		if err := w.notify(w.attrEvent(StateOpen, a)); err != nil {
+			return err
+		}
+	}
+	if w.attrIndented {
+		w.attrIndented = false
+	} else {
+		w.printer.WriteByte(' ')
+	}
+	if value != nil {
+		w.printer.WriteString(name)
+		w.printer.WriteString(`="`)
+		if escape {
+			w.printer.EscapeAttrBytes(value)
+		} else {
+			w.printer.Write(value)
+		}
+		w.printer.WriteByte('"')
+		if err := w.printer.cachedWriteError(); err != nil {
+			return err
+		}
+	} else if err := w.printer.printAttrBare(name, a.Value); err != nil {
 		return err
 	}
 	if w.Indenter != nil {
-		w.last = Event{StateEnded, AttrNode, 0}
+		w.last = w.attrEvent(StateEnded, a)
+	}
+	if len(w.listeners) > 0 {
+		ev := w.attrEvent(StateEnded, a)
+		ev.Bytes = int(w.printer.Offset() - start)
+		return w.notify(ev)
 	}
 	return nil
 }
+
+func (w *Writer) attrEvent(state NodeState, a Attr) Event {
+	ev := w.leafEvent(state, AttrNode)
+	ev.Prefix, ev.Name, ev.Value, ev.URI = a.Prefix, a.Name, a.Value, a.URI
+	if ev.URI == "" && a.Prefix != "" {
+		ev.URI = w.lookupNS(a.Prefix)
+	}
+	return ev
+}
+
+// bufferAttrs reports whether attributes should be held back until the
+// current element is opened, either to sort them or because the Indenter
+// needs to see all of them at once.
+func (w *Writer) bufferAttrs() bool {
+	if w.AttrOrder != AttrOrderNone {
+		return true
+	}
+	if b, ok := w.Indenter.(attrBufferer); ok {
+		return b.bufferAttrs()
+	}
+	return false
+}
+
+// flushAttrs writes the attributes buffered while the current element was
+// open, in the order specified by Writer.AttrOrder.
+func (w *Writer) flushAttrs() error {
+	attrs := w.attrs
+	if w.AttrOrder != AttrOrderNone {
+		sortAttrs(attrs, w.AttrOrder)
+	}
+
+	var err error
+	for _, a := range attrs {
+		name := a.Name
+		if a.Prefix != "" {
+			name = a.Prefix + ":" + name
+		}
+		if err = w.printAttr(a, nil, false, name); err != nil {
+			break
+		}
+	}
+	for i := range attrs {
+		attrs[i] = Attr{}
+	}
+	w.attrs = attrs[:0]
+	return err
+}
+
+// lookupNS finds the URI bound to a prefix by the Elem.URI or Attr.URI
+// of the current element or one of its ancestors.
+func (w *Writer) lookupNS(prefix string) string {
+	if prefix == "xml" {
+		return xmlNamespace
+	}
+	for i := w.current; i >= 0; i-- {
+		if w.nodes[i].kind != ElemNode {
+			continue
+		}
+		for _, ns := range w.nodes[i].elem.namespaces {
+			if ns.prefix == prefix {
+				return ns.uri
+			}
+		}
+	}
+	return ""
+}
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// Number of buffered attributes above which sort.Stable is used instead of
+// an insertion sort.
+const smallAttrSort = 16
+
+// AttrOrder controls the order in which attributes are written. See
+// Writer.AttrOrder.
+type AttrOrder int
+
+const (
+	// AttrOrderNone writes attributes in the order they are passed to the
+	// Writer.
+	AttrOrderNone AttrOrder = iota
+
+	// AttrOrderName sorts attributes by their qualified name, i.e.
+	// "prefix:name".
+	AttrOrderName
+
+	// AttrOrderNamespace sorts attributes by namespace URI, then by local
+	// name. Unqualified attributes have no namespace, so they come first.
+	AttrOrderNamespace
+)
+
+// less reports whether a should be written before b. Namespace declarations
+// always come first, sorted by the prefix they declare.
+func (o AttrOrder) less(a, b *Attr) bool {
+	ad, bd := a.isNSDecl(), b.isNSDecl()
+	if ad != bd {
+		return ad
+	}
+	if ad {
+		return a.declaredPrefix() < b.declaredPrefix()
+	}
+	if o == AttrOrderNamespace {
+		if a.URI != b.URI {
+			return a.URI < b.URI
+		}
+		return a.Name < b.Name
+	}
+	return qnameLess(a.Prefix, a.Name, b.Prefix, b.Name)
+}
+
+func (a *Attr) isNSDecl() bool {
+	return a.Prefix == "xmlns" || (a.Prefix == "" && a.Name == "xmlns")
+}
+
+func (a *Attr) declaredPrefix() string {
+	if a.Prefix == "" {
+		return ""
+	}
+	return a.Name
+}
+
+// qnameLess compares "ap:an" with "bp:bn" without building either string.
+func qnameLess(ap, an, bp, bn string) bool {
+	al, bl := qnameLen(ap, an), qnameLen(bp, bn)
+	for i := 0; i < al && i < bl; i++ {
+		ac, bc := qnameByte(ap, an, i), qnameByte(bp, bn, i)
+		if ac != bc {
+			return ac < bc
+		}
+	}
+	return al < bl
+}
+
+func qnameLen(prefix, name string) int {
+	if prefix == "" {
+		return len(name)
+	}
+	return len(prefix) + 1 + len(name)
+}
+
+func qnameByte(prefix, name string, i int) byte {
+	if prefix == "" {
+		return name[i]
+	}
+	if i < len(prefix) {
+		return prefix[i]
+	} else if i == len(prefix) {
+		return ':'
+	}
+	return name[i-len(prefix)-1]
+}
+
+func sortAttrs(attrs []Attr, order AttrOrder) {
+	if len(attrs) <= smallAttrSort {
+		// Insertion sort avoids the allocations sort.Stable would cause by
+		// boxing the slice in an interface.
+		for i := 1; i < len(attrs); i++ {
+			for j := i; j > 0 && order.less(&attrs[j], &attrs[j-1]); j-- {
+				attrs[j], attrs[j-1] = attrs[j-1], attrs[j]
+			}
+		}
+	} else {
+		sort.Stable(attrSorter{attrs, order})
+	}
+}
+
+type attrSorter struct {
+	attrs []Attr
+	order AttrOrder
+}
+
+func (s attrSorter) Len() int           { return len(s.attrs) }
+func (s attrSorter) Less(i, j int) bool { return s.order.less(&s.attrs[i], &s.attrs[j]) }
+func (s attrSorter) Swap(i, j int)      { s.attrs[i], s.attrs[j] = s.attrs[j], s.attrs[i] }
@@ -0,0 +1,146 @@
+package xmlwriter
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"unicode/utf8"
+)
+
+// WithBinaryWrap sets Writer.BinaryWrap, which breaks base64 content into
+// lines. 76 is the line length used by MIME:
+//	w := xmlwriter.Open(b, xmlwriter.WithIndent(), xmlwriter.WithBinaryWrap(76))
+func WithBinaryWrap(width int) Option {
+	return func(w *Writer) {
+		w.BinaryWrap = width
+	}
+}
+
+// WriteBase64 writes everything read from r as base64 encoded text inside the
+// current node, like xs:base64Binary, without reading it all into memory.
+func (w *Writer) WriteBase64(r io.Reader) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteBase64", nil)
+
+	return copyClose(w.Base64Writer(), r)
+}
+
+// Base64Writer returns an io.WriteCloser which writes everything written to
+// it as base64 encoded text inside the current node. Close must be called
+// to write the final, padded block. Nothing else may be written to the
+// Writer until the Base64Writer is closed.
+func (w *Writer) Base64Writer() io.WriteCloser {
+	return base64.NewEncoder(base64.StdEncoding, &binaryWriter{w: w, wrap: true})
+}
+
+// WriteHex writes everything read from r as hex encoded text inside the
+// current node, like xs:hexBinary, without reading it all into memory.
+func (w *Writer) WriteHex(r io.Reader) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteHex", nil)
+
+	return copyClose(w.HexWriter(), r)
+}
+
+// HexWriter returns an io.WriteCloser which writes everything written to it
+// as hex encoded text inside the current node. Nothing else may be written to
+// the Writer until the HexWriter is closed.
+//
+// Hex content isn't wrapped, even if BinaryWrap is set, as xs:hexBinary
+// doesn't allow whitespace inside the value.
+func (w *Writer) HexWriter() io.WriteCloser {
+	return hexWriter{hex.NewEncoder(&binaryWriter{w: w})}
+}
+
+func copyClose(wc io.WriteCloser, r io.Reader) error {
+	if _, err := io.Copy(wc, r); err != nil {
+		return err
+	}
+	return wc.Close()
+}
+
+type hexWriter struct {
+	io.Writer
+}
+
+func (h hexWriter) Close() error { return nil }
+
+// binaryWriter receives the output of a base64 or hex encoder and writes it
+// as text, breaking it into lines if wrap and Writer.BinaryWrap are set.
+// Encoded content never needs escaping.
+type binaryWriter struct {
+	w    *Writer
+	wrap bool
+
+	// the column the next byte will be written at, which starts from
+	// wherever the content starts.
+	col     int
+	started bool
+
+	// newline and indent, and the column the indent ends at, worked out
+	// at the first Write.
+	brk       []byte
+	indentCol int
+
+	buf []byte
+}
+
+func (b *binaryWriter) Write(p []byte) (n int, err error) {
+	w := b.w
+	if w.err != nil {
+		return 0, w.failed
+	}
+	defer w.latch(&err)
+
+	width := w.BinaryWrap
+	wrap := b.wrap && width > 0
+	if wrap && b.brk == nil {
+		// new lines are indented to line up with the parent's start tag:
+		b.brk = append(b.brk, w.NewlineString...)
+		if li, ok := w.Indenter.(lineIndenter); ok {
+			b.brk = li.appendLineIndent(b.brk)
+		}
+		b.indentCol = utf8.RuneCount(b.brk[len(w.NewlineString):])
+	}
+	if err := w.beginText(len(p)); err != nil {
+		return 0, err
+	}
+	out := p
+	if wrap {
+		if !b.started {
+			b.started = true
+			b.col = w.printer.Col()
+		}
+		out = b.buf[:0]
+		for i := 0; i < len(p); {
+			// a line always gets at least one byte, even if the indent
+			// is wider than BinaryWrap:
+			if b.col >= width && b.col > b.indentCol {
+				out = append(out, b.brk...)
+				b.col = b.indentCol
+			}
+			end := i + width - b.col
+			if end <= i {
+				end = i + 1
+			}
+			if end > len(p) {
+				end = len(p)
+			}
+			out = append(out, p[i:end]...)
+			b.col += end - i
+			i = end
+		}
+		b.buf = out
+	}
+	if _, err := w.printer.Write(out); err != nil {
+		return 0, err
+	}
+	if err := w.endLeaf(TextNode); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
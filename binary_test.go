@@ -0,0 +1,85 @@
+package xmlwriter
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+)
+
+func TestWriteBase64(t *testing.T) {
+	b, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "data"}))
+	tt.OK(t, w.WriteBase64(iotest.OneByteReader(strings.NewReader("hello, world"))))
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, "<data>aGVsbG8sIHdvcmxk</data>", str(b, w))
+}
+
+func TestWriteHex(t *testing.T) {
+	b, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "data"}))
+	tt.OK(t, w.WriteHex(strings.NewReader("\x00\xffhi")))
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, "<data>00ff6869</data>", str(b, w))
+}
+
+func TestBase64Wrap(t *testing.T) {
+	in := bytes.Repeat([]byte{0xAB, 0xCD, 0xEF}, 60)
+	enc := base64.StdEncoding.EncodeToString(in)
+
+	b, w := open(WithBinaryWrap(76))
+	tt.OK(t, w.StartElem(Elem{Name: "data"}))
+	bw := w.Base64Writer()
+	for _, c := range in {
+		_, err := bw.Write([]byte{c})
+		tt.OK(t, err)
+	}
+	tt.OK(t, bw.Close())
+	tt.OK(t, w.EndAll())
+	// the first line starts after "<data>":
+	tt.Equals(t, "<data>"+enc[:70]+"\n"+enc[70:146]+"\n"+enc[146:222]+"\n"+enc[222:]+"</data>", str(b, w))
+}
+
+func TestBase64WrapIndent(t *testing.T) {
+	in := bytes.Repeat([]byte{0xAB, 0xCD, 0xEF}, 30)
+	enc := base64.StdEncoding.EncodeToString(in)
+
+	b, w := open(WithIndentString("  "), WithBinaryWrap(76))
+	tt.OK(t, w.Start(Elem{Name: "a"}, Elem{Name: "data"}))
+	tt.OK(t, w.WriteBase64(bytes.NewReader(in)))
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, "<a>\n  <data>"+enc[:68]+"\n  "+enc[68:]+"</data>\n</a>", str(b, w))
+}
+
+func TestHexNoWrap(t *testing.T) {
+	b, w := open(WithBinaryWrap(8))
+	tt.OK(t, w.StartElem(Elem{Name: "h"}))
+	tt.OK(t, w.WriteHex(strings.NewReader("0123456789")))
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, "<h>30313233343536373839</h>", str(b, w))
+}
+
+func TestBase64Invalid(t *testing.T) {
+	_, w := open()
+	tt.OK(t, w.StartComment(Comment{}))
+	err := w.WriteBase64(strings.NewReader("hello"))
+	tt.Pattern(t, `unexpected kind`, err.Error())
+	tt.Equals(t, err, w.Err())
+}
+
+func TestBase64ReadError(t *testing.T) {
+	for _, write := range []func(w *Writer) error{
+		func(w *Writer) error { return w.WriteBase64(iotest.TimeoutReader(strings.NewReader("hello"))) },
+		func(w *Writer) error { return w.WriteHex(iotest.TimeoutReader(strings.NewReader("hello"))) },
+	} {
+		_, w := open()
+		tt.OK(t, w.StartElem(Elem{Name: "data"}))
+		err := write(w)
+		tt.Equals(t, iotest.ErrTimeout, err)
+		tt.Equals(t, err, w.Err())
+		tt.Pattern(t, `already failed`, w.EndAll().Error())
+	}
+}
@@ -2,6 +2,7 @@ package xmlwriter
 
 import (
 	"fmt"
+	"unicode/utf8"
 )
 
 // CheckEncoding validates the characters in a Doc{} node's encoding="..."
@@ -50,40 +51,81 @@ func CheckName(name string) error {
 	return nil
 }
 
+// nameCache remembers names which have passed CheckName, so that names
+// which are written over and over again are only checked once. It is direct
+// mapped by length and first and last byte, which is cheap to work out and
+// spreads typical element and attribute names around well enough. The
+// names are compared in full, but when the same string is used again, as
+// with constants, the comparison stops at the pointer.
+type nameCache [64]string
+
+func (c *nameCache) check(name string) error {
+	if len(name) == 0 {
+		return nil
+	}
+	slot := &c[(len(name)*31+int(name[0])+int(name[len(name)-1]))%len(c)]
+	if *slot == name {
+		return nil
+	}
+	if err := CheckName(name); err != nil {
+		return err
+	}
+	*slot = name
+	return nil
+}
+
 // CheckChars ensures a string contains characters which are valid in a Text{}
 // node: https://www.w3.org/TR/xml/#NT-Char
 // The 'strict' argument (which xmlwriter should activate by default) ensures
 // that unicode characters referenced in the note are also excluded.
 func CheckChars(chars string, strict bool) error {
 	for i, rn := range chars {
-		if rn == 0x9 || rn == 0xA || rn == 0xD ||
-			(rn >= 0x20 && rn <= 0xD7FF) ||
-			(rn >= 0xE000 && rn <= 0xFFFD) ||
-			(rn >= 0x10000 && rn <= 0x10FFFF) {
-			continue
+		if !isValidChar(rn, strict) {
+			return fmt.Errorf("xmlwriter: invalid chars at position %d: %c", i, rn)
 		}
-		if strict {
-			// Document authors are encouraged to avoid "compatibility
-			// characters", as defined in section 2.3 of [Unicode]. The
-			// characters defined in the following ranges are also discouraged.
-			// They are either control characters or permanently undefined
-			// Unicode characters:
-			if (rn >= 0x7F && rn <= 0x84) || (rn >= 0x86 && rn <= 0x9F) || (rn >= 0xFDD0 && rn <= 0xFDEF) ||
-				// FIXME: these are't really ranges, we don't need >= and <=
-				(rn >= 0x1FFFE && rn <= 0x1FFFF) || (rn >= 0x2FFFE && rn <= 0x2FFFF) || (rn >= 0x3FFFE && rn <= 0x3FFFF) ||
-				(rn >= 0x4FFFE && rn <= 0x4FFFF) || (rn >= 0x5FFFE && rn <= 0x5FFFF) || (rn >= 0x6FFFE && rn <= 0x6FFFF) ||
-				(rn >= 0x7FFFE && rn <= 0x7FFFF) || (rn >= 0x8FFFE && rn <= 0x8FFFF) || (rn >= 0x9FFFE && rn <= 0x9FFFF) ||
-				(rn >= 0xAFFFE && rn <= 0xAFFFF) || (rn >= 0xBFFFE && rn <= 0xBFFFF) || (rn >= 0xCFFFE && rn <= 0xCFFFF) ||
-				(rn >= 0xDFFFE && rn <= 0xDFFFF) || (rn >= 0xEFFFE && rn <= 0xEFFFF) || (rn >= 0xFFFFE && rn <= 0xFFFFF) ||
-				(rn >= 0x10FFFE && rn <= 0x10FFFF) {
-				continue
-			}
+	}
+	return nil
+}
+
+// checkCharsBytes is CheckChars for a byte slice.
+func checkCharsBytes(chars []byte, strict bool) error {
+	for i := 0; i < len(chars); {
+		rn, size := utf8.DecodeRune(chars[i:])
+		if !isValidChar(rn, strict) {
+			return fmt.Errorf("xmlwriter: invalid chars at position %d: %c", i, rn)
 		}
-		return fmt.Errorf("xmlwriter: invalid chars at position %d: %c", i, rn)
+		i += size
 	}
 	return nil
 }
 
+func isValidChar(rn rune, strict bool) bool {
+	if rn == 0x9 || rn == 0xA || rn == 0xD ||
+		(rn >= 0x20 && rn <= 0xD7FF) ||
+		(rn >= 0xE000 && rn <= 0xFFFD) ||
+		(rn >= 0x10000 && rn <= 0x10FFFF) {
+		return true
+	}
+	if strict {
+		// Document authors are encouraged to avoid "compatibility
+		// characters", as defined in section 2.3 of [Unicode]. The
+		// characters defined in the following ranges are also discouraged.
+		// They are either control characters or permanently undefined
+		// Unicode characters:
+		if (rn >= 0x7F && rn <= 0x84) || (rn >= 0x86 && rn <= 0x9F) || (rn >= 0xFDD0 && rn <= 0xFDEF) ||
+			// FIXME: these are't really ranges, we don't need >= and <=
+			(rn >= 0x1FFFE && rn <= 0x1FFFF) || (rn >= 0x2FFFE && rn <= 0x2FFFF) || (rn >= 0x3FFFE && rn <= 0x3FFFF) ||
+			(rn >= 0x4FFFE && rn <= 0x4FFFF) || (rn >= 0x5FFFE && rn <= 0x5FFFF) || (rn >= 0x6FFFE && rn <= 0x6FFFF) ||
+			(rn >= 0x7FFFE && rn <= 0x7FFFF) || (rn >= 0x8FFFE && rn <= 0x8FFFF) || (rn >= 0x9FFFE && rn <= 0x9FFFF) ||
+			(rn >= 0xAFFFE && rn <= 0xAFFFF) || (rn >= 0xBFFFE && rn <= 0xBFFFF) || (rn >= 0xCFFFE && rn <= 0xCFFFF) ||
+			(rn >= 0xDFFFE && rn <= 0xDFFFF) || (rn >= 0xEFFFE && rn <= 0xEFFFF) || (rn >= 0xFFFFE && rn <= 0xFFFFF) ||
+			(rn >= 0x10FFFE && rn <= 0x10FFFF) {
+			return true
+		}
+	}
+	return false
+}
+
 // CheckPubID validates a string according to the following production rule:
 // https://www.w3.org/TR/xml/#NT-PubidLiteral
 func CheckPubID(pubid string) error {
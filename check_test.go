@@ -38,6 +38,22 @@ func TestCheckName(t *testing.T) {
 	}
 }
 
+func TestNameCache(t *testing.T) {
+	var c nameCache
+	testtool.OK(t, c.check("foo"))
+	testtool.OK(t, c.check("foo"))
+	testtool.Assert(t, c.check("f!o") != nil)
+
+	// "f!o" may share a slot with "foo", but it mustn't be cached:
+	testtool.Assert(t, c.check("f!o") != nil)
+	testtool.OK(t, c.check("foo"))
+
+	for i := 0; i < 200; i++ {
+		testtool.OK(t, c.check(fmt.Sprintf("n%d", i)))
+		testtool.Assert(t, c.check(fmt.Sprintf("-%d", i)) != nil)
+	}
+}
+
 var BenchErr error
 
 func BenchmarkCheckName(b *testing.B) {
@@ -55,5 +71,13 @@ func BenchmarkCheckName(b *testing.B) {
 				BenchErr = CheckName(v)
 			}
 		})
+
+		b.Run(fmt.Sprintf("cached/%d", sz), func(b *testing.B) {
+			var c nameCache
+			v := "\u0370" + strings.Repeat("\u203F", sz-1)
+			for i := 0; i < b.N; i++ {
+				BenchErr = c.check(v)
+			}
+		})
 	}
 }
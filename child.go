@@ -0,0 +1,172 @@
+package xmlwriter
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// childState is kept by a Writer returned by Writer.Child.
+type childState struct {
+	parent  *Writer
+	buf     bytes.Buffer
+	spliced bool
+
+	// the event for the child's first top-level node, which the parent's
+	// Indenter places when the child is spliced.
+	first Event
+}
+
+// Child returns a new Writer for a subtree which is written into w's current
+// node later, by Splice. Children can be filled on separate goroutines while w
+// carries on being used by its own; each child is buffered in memory until it
+// is spliced:
+//	parts := make([]*xmlwriter.Writer, len(items))
+//	var wg sync.WaitGroup
+//	for i := range items {
+//		parts[i] = w.Child()
+//		wg.Add(1)
+//		go func(i int) {
+//			defer wg.Done()
+//			parts[i].Write(items[i].Elem())
+//		}(i)
+//	}
+//	wg.Wait()
+//	err := w.Splice(parts...)
+//
+// The child starts with its own copy of w's open nodes, so it can use the
+// namespace prefixes they declare, it is checked against its parent node when
+// Enforce is set, and Events and Limits.MaxDepth see the same depths as
+// they would in w. It can't end the nodes it inherits.
+//
+// The child copies w's configuration, Limits and context. If w's Indenter is
+// a StandardIndenter or one of the indenters built on it in this package, the
+// child gets a copy of it which indents from w's current depth; any other
+// Indenter isn't safe to share between goroutines, so the child isn't
+// indented. A child can't see anything written into the node after it was
+// created, by w or by another child, so a SafeIndenter in a child won't know
+// that the node has mixed content if a sibling has written text into it, and
+// will still break lines inside the child's tags.
+//
+// Listeners and debug logging aren't copied, so w's Listeners don't see the
+// nodes written by a child.
+//
+// Child must be called by the goroutine using w. Every node started in the
+// child must be ended before it is spliced.
+func (w *Writer) Child() *Writer {
+	cs := &childState{parent: w}
+	c := newWriter(&cs.buf, func(c *Writer) {
+		c.Enforce = w.Enforce
+		c.StrictChars = w.StrictChars
+		c.Version = w.Version
+		c.InitialBufSize = w.InitialBufSize
+		c.NewlineString = w.NewlineString
+		c.BinaryWrap = w.BinaryWrap
+		c.AttrOrder = w.AttrOrder
+		c.limits = w.limits
+		c.ctx, c.done = w.ctx, w.done
+		c.Indenter = forkIndenter(w.Indenter)
+	})
+	c.encoding = w.encoding
+	c.child = cs
+
+	// the child's output carries on from where w's has got to, so that it
+	// wraps at the same place:
+	c.printer.col = w.printer.Col()
+
+	if w.current >= len(c.nodes) {
+		c.nodes = make([]node, w.current+initialNodeDepth)
+	}
+	opened := false
+	for i := 0; i <= w.current; i++ {
+		n := w.nodes[i]
+		n.elem.namespaces = append([]ns(nil), n.elem.namespaces...)
+		if n.state == StateOpen {
+			// w opens it when the child is spliced:
+			n.state = StateOpened
+			opened = true
+		}
+		c.nodes[i] = n
+	}
+	c.current = w.current
+	c.floor = w.current
+
+	if opened && c.Indenter != nil {
+		// the copy of w's Indenter hasn't seen the node opened yet:
+		err := c.Indenter.Indent(c, w.last, c.nodes[c.current].event(c))
+		c.latch(&err)
+	}
+	return c
+}
+
+// Splice writes the content of each of the children, which must have been
+// returned by w.Child, into w's current node in the order they are given.
+// w's current node must be the one the children were created in.
+//
+// If a child has failed, its error is returned and nothing more is written.
+// Each child can only be spliced once.
+func (w *Writer) Splice(children ...*Writer) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "Splice", func() string { return debugArg(len(children)) + " children" })
+
+	for _, c := range children {
+		if err := w.splice(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Writer) splice(c *Writer) error {
+	if c.child == nil || c.child.parent != w {
+		return fmt.Errorf("xmlwriter: writer is not a child of this writer")
+	}
+	if c.child.spliced {
+		return fmt.Errorf("xmlwriter: child writer has already been spliced")
+	}
+	if c.err != nil {
+		return c.err
+	}
+	if c.current != c.floor {
+		return fmt.Errorf("xmlwriter: child writer did not end %s node", c.nodes[c.current].kind.Name())
+	}
+	if w.current != c.floor {
+		return fmt.Errorf("xmlwriter: child writer was created at depth %d, found %d", c.floor, w.current)
+	}
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	c.child.spliced = true
+
+	out := c.child.buf.Bytes()
+	if len(out) == 0 {
+		return nil
+	}
+	if err := w.Next(); err != nil {
+		return err
+	}
+	if err := w.checkContext(); err != nil {
+		return err
+	}
+	if w.Indenter != nil && c.Indenter != nil {
+		if err := w.writeIndent(c.child.first); err != nil {
+			return err
+		}
+		w.last = c.last
+	}
+	w.printer.Write(out)
+	if err := w.printer.cachedWriteError(); err != nil {
+		return err
+	}
+
+	w.stats.Elems += c.stats.Elems
+	w.stats.Attrs += c.stats.Attrs
+	w.stats.Texts += c.stats.Texts
+	w.stats.Comments += c.stats.Comments
+	w.stats.CDatas += c.stats.CDatas
+	if c.stats.MaxDepth > w.stats.MaxDepth {
+		w.stats.MaxDepth = c.stats.MaxDepth
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package xmlwriter
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+)
+
+func childItem(i int) Elem {
+	return Elem{Prefix: "c", Name: "item", Attrs: []Attr{{Name: "id", Value: strconv.Itoa(i)}}, Content: []Writable{
+		Elem{Name: "name", Content: []Writable{Text("a&b")}},
+		Comment{Content: "x"},
+	}}
+}
+
+func TestChildSplice(t *testing.T) {
+	for _, opt := range []Option{WithIndent(), WithWrap(20), func(*Writer) {}} {
+		root := Elem{Prefix: "c", Name: "catalog", URI: "urn:c"}
+
+		b, w := open(opt)
+		ec := &ErrCollector{}
+		ec.Must(w.StartElem(root), w.StartElem(Elem{Name: "items"}))
+
+		parts := make([]*Writer, 5)
+		var wg sync.WaitGroup
+		for i := range parts {
+			parts[i] = w.Child()
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				ec := &ErrCollector{}
+				ec.Must(parts[i].WriteElem(childItem(i)), parts[i].WriteText("t"))
+				ec.Must(parts[i].WriteElem(childItem(i+5)), parts[i].WriteText("t"))
+			}(i)
+		}
+		wg.Wait()
+		for i := 0; i < 5; i += 2 {
+			ec.Must(w.Splice(parts[i]))
+		}
+		ec.Must(w.Splice(parts[1], parts[3]))
+		ec.Must(w.EndAll())
+
+		tt.Equals(t, directOrder(opt, root, []int{0, 5, 2, 7, 4, 9, 1, 6, 3, 8}), str(b, w))
+
+		tt.Equals(t, 22, w.Stats().Elems)
+		tt.Equals(t, 3, w.Stats().MaxDepth)
+	}
+}
+
+// directOrder writes the same nodes as TestChildSplice without children.
+func directOrder(opt Option, root Elem, order []int) string {
+	b, w := open(opt)
+	ec := &ErrCollector{}
+	ec.Must(w.StartElem(root), w.StartElem(Elem{Name: "items"}))
+	for _, i := range order {
+		ec.Must(w.WriteElem(childItem(i)), w.WriteText("t"))
+	}
+	ec.Must(w.EndAll())
+	return str(b, w)
+}
+
+func TestChildWrap(t *testing.T) {
+	write := func(w *Writer) error {
+		return w.Write(Text("one two three four five six"))
+	}
+
+	b, w := open(WithWrap(20))
+	ec := &ErrCollector{}
+	ec.Must(w.StartElem(Elem{Name: "p"}), w.WriteText("hello friend "))
+	c := w.Child()
+	ec.Must(write(c), w.Splice(c), w.EndAll())
+
+	direct, dw := open(WithWrap(20))
+	ec.Must(dw.StartElem(Elem{Name: "p"}), dw.WriteText("hello friend "), write(dw), dw.EndAll())
+	tt.Equals(t, str(direct, dw), str(b, w))
+}
+
+func TestChildNested(t *testing.T) {
+	b, w := open(WithIndent())
+	ec := &ErrCollector{}
+	ec.Must(w.StartElem(Elem{Name: "a"}))
+	c := w.Child()
+	ec.Must(c.StartElem(Elem{Name: "b"}))
+	cc := c.Child()
+	ec.Must(cc.WriteElem(Elem{Name: "c"}))
+	ec.Must(c.Splice(cc), c.EndAll())
+	ec.Must(w.Splice(c), w.EndAll())
+	tt.Equals(t, "<a>\n <b>\n  <c/>\n </b>\n</a>", str(b, w))
+}
+
+func TestChildEmpty(t *testing.T) {
+	b, w := open()
+	ec := &ErrCollector{}
+	ec.Must(w.StartElem(Elem{Name: "a"}), w.Splice(w.Child()), w.EndAll())
+	tt.Equals(t, "<a/>", str(b, w))
+}
+
+func TestChildErrors(t *testing.T) {
+	openParent := func() *Writer {
+		_, w := open()
+		tt.OK(t, w.StartElem(Elem{Name: "a"}))
+		return w
+	}
+
+	w := openParent()
+	tt.Pattern(t, `could not pop`, w.Child().EndAny().Error())
+	tt.OK(t, w.Child().EndAll())
+
+	w = openParent()
+	c := w.Child()
+	tt.OK(t, c.StartElem(Elem{Name: "b"}))
+	tt.Pattern(t, `did not end elem`, w.Splice(c).Error())
+
+	w = openParent()
+	c = w.Child()
+	tt.Pattern(t, `unexpected kind elem`, c.StartDoc(Doc{}).Error())
+	tt.Pattern(t, `unexpected kind elem`, c.Start(Elem{Name: "b"}, Doc{}).Error())
+	tt.Pattern(t, `unexpected kind elem`, w.Splice(c).Error())
+
+	w = openParent()
+	c = w.Child()
+	tt.OK(t, w.Splice(c))
+	tt.Pattern(t, `already been spliced`, w.Splice(c).Error())
+
+	w = openParent()
+	tt.Pattern(t, `not a child`, w.Splice(openParent().Child()).Error())
+
+	w = openParent()
+	c = w.Child()
+	tt.OK(t, w.StartElem(Elem{Name: "b"}))
+	tt.Pattern(t, `created at depth 0, found 1`, w.Splice(c).Error())
+}
@@ -0,0 +1,43 @@
+package xmlwriter
+
+import (
+	"context"
+	"io"
+)
+
+// WithContext makes the Writer check ctx for cancellation. Every call that
+// starts, writes or ends a node, and every call to Flush, returns ctx.Err()
+// once ctx is done:
+//	w := xmlwriter.Open(rw, xmlwriter.WithContext(r.Context()))
+//
+// The check does not block. Once it has failed, the Writer is left in a
+// failed state: output which has not yet been flushed is discarded, and all
+// further calls return ctx.Err().
+func WithContext(ctx context.Context) Option {
+	return func(w *Writer) {
+		w.ctx = ctx
+		w.done = ctx.Done()
+	}
+}
+
+// OpenContext opens a Writer using the UTF-8 encoding which checks ctx for
+// cancellation, see WithContext.
+func OpenContext(ctx context.Context, w io.Writer, options ...Option) *Writer {
+	xw := Open(w, options...)
+	WithContext(ctx)(xw)
+	return xw
+}
+
+func (w *Writer) checkContext() error {
+	if w.done == nil {
+		return nil
+	}
+	select {
+	case <-w.done:
+		err := w.ctx.Err()
+		w.printer.discard(err)
+		return err
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+package xmlwriter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+)
+
+func TestContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &bytes.Buffer{}
+	w := OpenContext(ctx, b)
+	tt.OK(t, w.Start(Elem{Name: "a"}))
+	tt.OK(t, w.Write(Text("1")))
+	tt.OK(t, w.Flush())
+	tt.OK(t, w.Write(Text("2")))
+
+	cancel()
+	tt.Equals(t, context.Canceled, w.Write(Text("3")))
+	tt.Equals(t, context.Canceled, w.Start(Elem{Name: "b"}))
+	tt.Equals(t, context.Canceled, w.WriteAttr(Attr{Name: "b"}))
+	tt.Equals(t, context.Canceled, w.WriteRaw("x"))
+	tt.Equals(t, context.Canceled, w.EndAll())
+	tt.Equals(t, context.Canceled, w.Flush())
+	tt.Equals(t, "<a>1", b.String())
+}
+
+func TestContextDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	<-ctx.Done()
+
+	b, w := open(WithContext(ctx))
+	tt.Equals(t, context.DeadlineExceeded, w.Start(Doc{}))
+	tt.Equals(t, 0, b.Len())
+}
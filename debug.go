@@ -0,0 +1,273 @@
+package xmlwriter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// DebugLogger receives debug output from a Writer, see WithDebug. It is
+// satisfied by *slog.Logger.
+type DebugLogger interface {
+	Debug(msg string, args ...interface{})
+}
+
+// WithDebug logs every call to a method on the Writer which writes to the
+// document, along with its arguments, the Depth the call left the Writer
+// at, and the number of bytes it wrote before encoding. Every Event raised
+// while handling the call is also logged, before the call itself. Calls
+// made by other calls, like EndElem calling End, are not logged separately.
+//
+// args are passed to the logger as key/value pairs, like log/slog expects:
+//	w := xmlwriter.Open(b, xmlwriter.WithDebug(slog.Default()))
+//
+func WithDebug(logger DebugLogger) Option {
+	return func(w *Writer) {
+		w.debug = logger
+		w.AddListener(ListenerFunc(w.debugEvent))
+	}
+}
+
+// WithDebugWriter is like WithDebug, but writes one line of text per
+// message to out:
+//	w := xmlwriter.Open(b, xmlwriter.WithDebugWriter(os.Stderr))
+func WithDebugWriter(out io.Writer) Option {
+	return WithDebug(&debugWriter{out: out})
+}
+
+type debugWriter struct {
+	out io.Writer
+}
+
+// Debug writes msg followed by each key/value pair in args as key=value.
+func (d *debugWriter) Debug(msg string, args ...interface{}) {
+	var sb strings.Builder
+	sb.WriteString(msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		if s, ok := args[i+1].(string); ok {
+			fmt.Fprintf(&sb, " %v=%q", args[i], s)
+		} else {
+			fmt.Fprintf(&sb, " %v=%v", args[i], args[i+1])
+		}
+	}
+	sb.WriteByte('\n')
+	io.WriteString(d.out, sb.String())
+}
+
+func (w *Writer) debugEvent(_ *Writer, ev Event) error {
+	name := ev.Name
+	if ev.Prefix != "" {
+		name = ev.Prefix + ":" + name
+	}
+	w.debug.Debug("event",
+		"state", ev.State.Name(), "node", ev.Node.Name(), "name", name,
+		"depth", ev.Depth, "bytes", ev.Bytes)
+	return nil
+}
+
+// trace logs a call once it has finished, see exit.
+func (w *Writer) trace(method string, args func() string, err error) {
+	var arg string
+	if args != nil {
+		arg = args()
+	}
+	bytes := w.printer.Offset() - w.callStart
+	if err != nil {
+		w.debug.Debug(method, "args", arg, "depth", w.current, "bytes", bytes, "err", err)
+	} else {
+		w.debug.Debug(method, "args", arg, "depth", w.current, "bytes", bytes)
+	}
+}
+
+// debugArg formats a single argument to a traced call. Nodes are written
+// like Go composite literals, leaving out fields which are empty.
+func debugArg(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case Text:
+		return "Text(" + strconv.Quote(string(v)) + ")"
+	case Raw:
+		return "Raw(" + strconv.Quote(string(v)) + ")"
+	case CommentContent:
+		return "CommentContent(" + strconv.Quote(string(v)) + ")"
+	case CDataContent:
+		return "CDataContent(" + strconv.Quote(string(v)) + ")"
+	case TextHole:
+		return "TextHole(" + strconv.Quote(string(v)) + ")"
+	case Elem:
+		var f debugFields
+		f.str("Prefix", v.Prefix)
+		f.str("URI", v.URI)
+		f.str("Name", v.Name)
+		if len(v.Attrs) > 0 {
+			f.add("Attrs", "["+debugAttrs(v.Attrs)+"]")
+		}
+		if len(v.Content) > 0 {
+			f.add("Content", "["+debugNodes(v.Content)+"]")
+		}
+		if v.Full {
+			f.add("Full", "true")
+		}
+		return "Elem{" + f.String() + "}"
+	case Attr:
+		var f debugFields
+		f.str("Prefix", v.Prefix)
+		f.str("URI", v.URI)
+		f.str("Name", v.Name)
+		f.str("Value", v.Value)
+		return "Attr{" + f.String() + "}"
+	case AttrHole:
+		var f debugFields
+		f.str("Prefix", v.Prefix)
+		f.str("Name", v.Name)
+		f.str("Hole", v.Hole)
+		return "AttrHole{" + f.String() + "}"
+	case Comment:
+		var f debugFields
+		f.str("Content", v.Content)
+		return "Comment{" + f.String() + "}"
+	case CData:
+		var f debugFields
+		f.str("Content", v.Content)
+		return "CData{" + f.String() + "}"
+	case PI:
+		var f debugFields
+		f.str("Target", v.Target)
+		f.str("Content", v.Content)
+		return "PI{" + f.String() + "}"
+	case Doc:
+		var f debugFields
+		if v.SuppressEncoding {
+			f.add("SuppressEncoding", "true")
+		}
+		if v.ForcedEncoding != nil {
+			f.add("ForcedEncoding", strconv.Quote(*v.ForcedEncoding))
+		}
+		if v.SuppressVersion {
+			f.add("SuppressVersion", "true")
+		}
+		if v.ForcedVersion != nil {
+			f.add("ForcedVersion", strconv.Quote(*v.ForcedVersion))
+		}
+		if v.Standalone != nil {
+			f.add("Standalone", strconv.FormatBool(*v.Standalone))
+		}
+		return "Doc{" + f.String() + "}"
+	case DTD:
+		var f debugFields
+		f.str("Name", v.Name)
+		f.str("PublicID", v.PublicID)
+		f.str("SystemID", v.SystemID)
+		return "DTD{" + f.String() + "}"
+	case DTDElem:
+		var f debugFields
+		f.str("Name", v.Name)
+		f.str("Decl", v.Decl)
+		return "DTDElem{" + f.String() + "}"
+	case DTDEntity:
+		var f debugFields
+		f.str("Name", v.Name)
+		f.str("Content", v.Content)
+		if v.IsPE {
+			f.add("IsPE", "true")
+		}
+		f.str("PublicID", v.PublicID)
+		f.str("SystemID", v.SystemID)
+		f.str("NDataID", v.NDataID)
+		return "DTDEntity{" + f.String() + "}"
+	case DTDAttList:
+		var f debugFields
+		f.str("Name", v.Name)
+		if len(v.Attrs) > 0 {
+			attrs := make([]string, len(v.Attrs))
+			for i, a := range v.Attrs {
+				attrs[i] = debugArg(a)
+			}
+			f.add("Attrs", "["+strings.Join(attrs, ", ")+"]")
+		}
+		return "DTDAttList{" + f.String() + "}"
+	case DTDAttr:
+		var f debugFields
+		f.str("Name", v.Name)
+		f.str("Type", string(v.Type))
+		if v.Default != 0 {
+			f.add("Default", strconv.Itoa(int(v.Default)))
+		}
+		f.str("Value", v.Value)
+		return "DTDAttr{" + f.String() + "}"
+	case Notation:
+		var f debugFields
+		f.str("Name", v.Name)
+		f.str("SystemID", v.SystemID)
+		f.str("PublicID", v.PublicID)
+		return "Notation{" + f.String() + "}"
+	}
+	return fmt.Sprint(v)
+}
+
+// debugFields collects the fields of a node for debugArg.
+type debugFields struct {
+	sb strings.Builder
+}
+
+func (f *debugFields) add(name, value string) {
+	if f.sb.Len() > 0 {
+		f.sb.WriteString(", ")
+	}
+	f.sb.WriteString(name)
+	f.sb.WriteByte(':')
+	f.sb.WriteString(value)
+}
+
+func (f *debugFields) str(name, value string) {
+	if value != "" {
+		f.add(name, strconv.Quote(value))
+	}
+}
+
+func (f *debugFields) String() string { return f.sb.String() }
+
+// The variadic arguments to traced calls are formatted one by one, rather
+// than passing the slices as interfaces, which would force them on to the
+// heap even when tracing is off.
+
+func debugNodes(nodes []Writable) string {
+	args := make([]string, len(nodes))
+	for i, n := range nodes {
+		args[i] = debugArg(n)
+	}
+	return strings.Join(args, ", ")
+}
+
+func debugStartables(nodes []Startable) string {
+	args := make([]string, len(nodes))
+	for i, n := range nodes {
+		args[i] = debugArg(n)
+	}
+	return strings.Join(args, ", ")
+}
+
+func debugAttrs(attrs []Attr) string {
+	args := make([]string, len(attrs))
+	for i, a := range attrs {
+		args[i] = debugArg(a)
+	}
+	return strings.Join(args, ", ")
+}
+
+func debugNames(name []string) string {
+	args := make([]string, len(name))
+	for i, n := range name {
+		args[i] = debugArg(n)
+	}
+	return strings.Join(args, ", ")
+}
+
+func debugEnd(kind NodeKind, name []string) string {
+	if len(name) == 0 {
+		return kind.Name()
+	}
+	return kind.Name() + ", " + debugNames(name)
+}
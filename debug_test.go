@@ -0,0 +1,76 @@
+package xmlwriter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+)
+
+func TestDebugWriter(t *testing.T) {
+	var log bytes.Buffer
+	b, w := open(WithDebugWriter(&log))
+	ec := &ErrCollector{}
+	ec.Must(
+		w.StartElem(Elem{Name: "foo"}),
+		w.WriteAttr(Attr{Name: "a", Value: "1"}),
+		w.WriteText("hi"),
+		w.EndElem("foo"),
+	)
+	tt.Equals(t, `<foo a="1">hi</foo>`, str(b, w))
+
+	lines := strings.Split(strings.TrimSpace(log.String()), "\n")
+	tt.Equals(t, []string{
+		`event state="open" node="elem" name="foo" depth=0 bytes=0`,
+		`StartElem args="Elem{Name:\"foo\"}" depth=0 bytes=4`,
+		`event state="open" node="attr" name="a" depth=1 bytes=0`,
+		`event state="ended" node="attr" name="a" depth=1 bytes=6`,
+		`WriteAttr args="Attr{Name:\"a\", Value:\"1\"}" depth=0 bytes=6`,
+		`event state="opened" node="elem" name="foo" depth=0 bytes=0`,
+		`event state="open" node="text" name="" depth=1 bytes=0`,
+		`event state="ended" node="text" name="" depth=1 bytes=2`,
+		`WriteText args="\"hi\"" depth=0 bytes=3`,
+		`event state="ended" node="elem" name="foo" depth=0 bytes=19`,
+		`EndElem args="\"foo\"" depth=-1 bytes=6`,
+		`Flush args="" depth=-1 bytes=0`,
+	}, lines)
+}
+
+type debugRecorder struct {
+	msgs []string
+	args [][]interface{}
+}
+
+func (d *debugRecorder) Debug(msg string, args ...interface{}) {
+	d.msgs = append(d.msgs, msg)
+	d.args = append(d.args, args)
+}
+
+func TestDebugLogger(t *testing.T) {
+	rec := &debugRecorder{}
+	_, w := open(WithDebug(rec))
+	tt.OK(t, w.Start(Elem{Name: "foo"}, Elem{Name: "bar"}))
+	tt.Assert(t, w.End(ElemNode, "nope") != nil)
+	tt.OK(t, w.EndAll())
+
+	var calls []string
+	for _, m := range rec.msgs {
+		if m != "event" {
+			calls = append(calls, m)
+		}
+	}
+	// EndAll isn't followed by the End calls it makes:
+	tt.Equals(t, []string{"Start", "End", "EndAll"}, calls)
+
+	last := rec.args[len(rec.args)-1]
+	tt.Equals(t, []interface{}{"args", "", "depth", -1, "bytes", int64(8)}, last)
+
+	for i, m := range rec.msgs {
+		if m == "End" {
+			args := rec.args[i]
+			tt.Equals(t, "elem, \"nope\"", args[1])
+			tt.Equals(t, "err", args[6])
+		}
+	}
+}
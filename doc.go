@@ -35,6 +35,15 @@ xmlwriter options are based on Dave Cheney's functional options pattern
 Provided options are:
   - WithIndent()
   - WithIndentString(string)
+  - WithWrap(int)
+  - WithAttrOrder(AttrOrder)
+  - WithListener(Listener)
+  - WithDebug(DebugLogger)
+  - WithDebugWriter(io.Writer)
+  - WithElemSpans(func(ElemSpan))
+  - WithLimits(Limits)
+  - WithContext(context.Context)
+  - WithBinaryWrap(int)
 
 
 Overview
@@ -208,6 +217,20 @@ xmlwriter.Attr{} values can be assigned from any golang primitive like so:
 	Attr{Name: "foo"}.Float64(1.234)
 
 
+Concurrency
+
+A Writer must only be used by one goroutine at a time. SyncWriter wraps a
+Writer so that several goroutines can write complete subtrees into it, one at
+a time:
+
+	sw := xmlwriter.NewSyncWriter(w)
+	err := sw.Block(xmlwriter.Elem{Name: "event"}, xmlwriter.Text("hello"))
+
+To write a large document faster, Writer.Child returns a Writer for a subtree
+which can be filled on another goroutine, then added to the document in order
+by Writer.Splice.
+
+
 Encodings
 
 xmlwriter supports encoders from the golang.org/x/text/encoding package.
@@ -0,0 +1,392 @@
+package dsig
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+type nsDecl struct {
+	prefix string
+	uri    string
+}
+
+type canonAttr struct {
+	prefix string
+	uri    string
+	name   string
+	value  string
+}
+
+func (a canonAttr) qname() string {
+	if a.prefix != "" {
+		return a.prefix + ":" + a.name
+	}
+	return a.name
+}
+
+type canonElem struct {
+	prefix  string
+	name    string
+	attrs   []canonAttr
+	emitted bool
+
+	// marks into canonicalizer.scope and canonicalizer.rendered, used to
+	// discard the namespaces declared by this element when it ends.
+	scope    int
+	rendered int
+}
+
+func (e *canonElem) qname() string {
+	if e.prefix != "" {
+		return e.prefix + ":" + e.name
+	}
+	return e.name
+}
+
+// canonicalizer produces the Exclusive XML Canonicalization (without
+// comments) of a single subtree from a stream of writer calls:
+// https://www.w3.org/TR/xml-exc-c14n/
+//
+// Every element is tracked so that the namespace context is known, but
+// output is only produced once an element whose idAttr matches id has been
+// opened. If id is empty, the first element is captured.
+type canonicalizer struct {
+	out    io.Writer
+	idAttr string
+	id     string
+
+	stack    []canonElem
+	scope    []nsDecl
+	rendered []nsDecl
+
+	// stack index of the captured element, -1 if not capturing.
+	capture int
+	found   bool
+	done    bool
+	sealed  bool
+	err     error
+}
+
+func newCanonicalizer(out io.Writer, idAttr, id string) *canonicalizer {
+	return &canonicalizer{out: out, idAttr: idAttr, id: id, capture: -1}
+}
+
+func (c *canonicalizer) capturing() bool {
+	return c.capture >= 0 && !c.sealed
+}
+
+func (c *canonicalizer) declare(prefix, uri string) {
+	c.scope = append(c.scope, nsDecl{prefix, uri})
+}
+
+func (c *canonicalizer) lookup(decls []nsDecl, prefix string) (uri string, ok bool) {
+	if prefix == "xml" {
+		return xmlNamespace, true
+	}
+	for i := len(decls) - 1; i >= 0; i-- {
+		if decls[i].prefix == prefix {
+			return decls[i].uri, true
+		}
+	}
+	return "", false
+}
+
+func (c *canonicalizer) startElem(prefix, uri, name string) error {
+	if err := c.flushStart(); err != nil {
+		return err
+	}
+	c.stack = append(c.stack, canonElem{
+		prefix:   prefix,
+		name:     name,
+		scope:    len(c.scope),
+		rendered: len(c.rendered),
+	})
+
+	// xmlwriter only declares a namespace for an element if both the prefix
+	// and the URI are present.
+	if prefix != "" && uri != "" {
+		c.declare(prefix, uri)
+	}
+	return nil
+}
+
+func (c *canonicalizer) attr(prefix, uri, name, value string) error {
+	if len(c.stack) == 0 {
+		return fmt.Errorf("dsig: attribute written outside an element")
+	}
+	top := &c.stack[len(c.stack)-1]
+	if top.emitted {
+		return fmt.Errorf("dsig: attribute written after element was opened")
+	}
+
+	switch {
+	case prefix == "xmlns":
+		c.declare(name, value)
+	case prefix == "" && name == "xmlns":
+		c.declare("", value)
+	default:
+		if prefix != "" && uri != "" {
+			c.declare(prefix, uri)
+		}
+		top.attrs = append(top.attrs, canonAttr{prefix: prefix, name: name, value: value})
+	}
+	return nil
+}
+
+func (c *canonicalizer) text(s string) error {
+	if err := c.flushStart(); err != nil {
+		return err
+	}
+	if c.capturing() {
+		c.escapeText(s)
+	}
+	return c.err
+}
+
+func (c *canonicalizer) pi(target, content string) error {
+	if err := c.flushStart(); err != nil {
+		return err
+	}
+	if c.capturing() {
+		c.writeString("<?")
+		c.writeString(target)
+		if content != "" {
+			c.writeString(" ")
+			c.writeString(content)
+		}
+		c.writeString("?>")
+	}
+	return c.err
+}
+
+// comment flushes any pending start tag; comments are not part of the
+// canonical form.
+func (c *canonicalizer) comment() error {
+	return c.flushStart()
+}
+
+func (c *canonicalizer) endElem() error {
+	if len(c.stack) == 0 {
+		return fmt.Errorf("dsig: no element to end")
+	}
+	if err := c.flushStart(); err != nil {
+		return err
+	}
+	idx := len(c.stack) - 1
+	top := &c.stack[idx]
+	if c.capturing() {
+		c.writeString("</")
+		c.writeString(top.qname())
+		c.writeString(">")
+	}
+	c.scope = c.scope[:top.scope]
+	c.rendered = c.rendered[:top.rendered]
+	c.stack = c.stack[:idx]
+
+	if c.capture == idx {
+		c.capture = -1
+		c.done = true
+	}
+	return c.err
+}
+
+// seal finishes the captured element early by writing its end tag. This
+// is used for enveloped signatures, where the signature is the last child
+// of the signed element and must be excluded from the digest.
+func (c *canonicalizer) seal() error {
+	if err := c.flushStart(); err != nil {
+		return err
+	}
+	if c.capture != len(c.stack)-1 || !c.capturing() {
+		return fmt.Errorf("dsig: current element is not the signed element")
+	}
+	c.writeString("</")
+	c.writeString(c.stack[c.capture].qname())
+	c.writeString(">")
+	c.sealed = true
+	c.done = true
+	return c.err
+}
+
+func (c *canonicalizer) flushStart() error {
+	if len(c.stack) == 0 {
+		return c.err
+	}
+	top := &c.stack[len(c.stack)-1]
+	if top.emitted {
+		return c.err
+	}
+	top.emitted = true
+
+	if c.capture < 0 && !c.found {
+		if c.id == "" {
+			c.capture, c.found = len(c.stack)-1, true
+		} else {
+			for _, a := range top.attrs {
+				if a.qname() == c.idAttr && a.value == c.id {
+					c.capture, c.found = len(c.stack)-1, true
+					break
+				}
+			}
+		}
+	}
+	if !c.capturing() {
+		return c.err
+	}
+	return c.writeStart(top)
+}
+
+func (c *canonicalizer) writeStart(e *canonElem) error {
+	var decls []nsDecl
+
+	use := func(prefix string) error {
+		if prefix == "xml" {
+			return nil
+		}
+		for _, d := range decls {
+			if d.prefix == prefix {
+				return nil
+			}
+		}
+		uri, ok := c.lookup(c.scope, prefix)
+		if !ok && prefix != "" {
+			return fmt.Errorf("dsig: namespace prefix %q is not declared", prefix)
+		}
+		rendered, _ := c.lookup(c.rendered, prefix)
+		if uri != rendered {
+			decls = append(decls, nsDecl{prefix, uri})
+		}
+		return nil
+	}
+
+	if err := use(e.prefix); err != nil {
+		return err
+	}
+	for i := range e.attrs {
+		a := &e.attrs[i]
+		if a.prefix == "" {
+			continue
+		}
+		if err := use(a.prefix); err != nil {
+			return err
+		}
+		a.uri, _ = c.lookup(c.scope, a.prefix)
+	}
+
+	// Namespace nodes are sorted by prefix (the default namespace has an
+	// empty prefix so it sorts first), attributes by namespace URI then
+	// local name. Elements rarely have many attributes, so insertion sort
+	// is fine here.
+	for i := 1; i < len(decls); i++ {
+		for j := i; j > 0 && decls[j].prefix < decls[j-1].prefix; j-- {
+			decls[j], decls[j-1] = decls[j-1], decls[j]
+		}
+	}
+	attrs := e.attrs
+	for i := 1; i < len(attrs); i++ {
+		for j := i; j > 0 && attrLess(attrs[j], attrs[j-1]); j-- {
+			attrs[j], attrs[j-1] = attrs[j-1], attrs[j]
+		}
+	}
+
+	c.writeString("<")
+	c.writeString(e.qname())
+	for _, d := range decls {
+		if d.prefix == "" {
+			c.writeString(` xmlns="`)
+		} else {
+			c.writeString(" xmlns:")
+			c.writeString(d.prefix)
+			c.writeString(`="`)
+		}
+		c.escapeAttr(d.uri)
+		c.writeString(`"`)
+	}
+	for _, a := range attrs {
+		c.writeString(" ")
+		c.writeString(a.qname())
+		c.writeString(`="`)
+		c.escapeAttr(a.value)
+		c.writeString(`"`)
+	}
+	c.writeString(">")
+	c.rendered = append(c.rendered, decls...)
+	return c.err
+}
+
+func attrLess(a, b canonAttr) bool {
+	if a.uri != b.uri {
+		return a.uri < b.uri
+	}
+	return a.name < b.name
+}
+
+func (c *canonicalizer) writeString(s string) {
+	if c.err != nil {
+		return
+	}
+	_, c.err = io.WriteString(c.out, s)
+}
+
+func (c *canonicalizer) escapeText(s string) {
+	c.escape(s, false)
+}
+
+func (c *canonicalizer) escapeAttr(s string) {
+	c.escape(s, true)
+}
+
+// escape mirrors the replacement of invalid characters performed by the
+// xmlwriter printer, so that the canonical form matches what a parser will
+// see when it reads the document back.
+func (c *canonicalizer) escape(s string, attr bool) {
+	last := 0
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRuneInString(s[i:])
+		i += width
+		var esc string
+		switch {
+		case r == '&':
+			esc = "&amp;"
+		case r == '<':
+			esc = "&lt;"
+		case r == '>' && !attr:
+			esc = "&gt;"
+		case r == '"' && attr:
+			esc = "&quot;"
+		case r == '\t' && attr:
+			esc = "&#x9;"
+		case r == '\n' && attr:
+			esc = "&#xA;"
+		case r == '\r' && attr:
+			esc = "&#xD;"
+		case r == '\r':
+			// Carriage returns in text are written unescaped, so a parser
+			// will see them as line breaks after end-of-line handling.
+			esc = "\n"
+			if i < len(s) && s[i] == '\n' {
+				esc = ""
+			}
+		case !isInCharacterRange(r) || (r == utf8.RuneError && width == 1):
+			esc = "\uFFFD"
+		default:
+			continue
+		}
+		c.writeString(s[last : i-width])
+		c.writeString(esc)
+		last = i
+	}
+	c.writeString(s[last:])
+}
+
+func isInCharacterRange(r rune) bool {
+	return r == 0x09 ||
+		r == 0x0A ||
+		r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
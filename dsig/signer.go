@@ -0,0 +1,422 @@
+/*
+Package dsig writes enveloped and detached XML Digital Signatures
+(https://www.w3.org/TR/xmldsig-core1/) while a document is being streamed
+through an xmlwriter.Writer.
+
+The element to sign is identified by an ID attribute. Nodes written through
+the Signer are passed to the underlying Writer unchanged, and the Exclusive
+XML Canonicalization of the identified element is hashed as it goes past, so
+the document never needs to be held in memory:
+
+	w := xmlwriter.Open(b)
+	s, err := dsig.NewSigner(w, key, "assertion-1")
+	ec := &xmlwriter.ErrCollector{}
+	defer ec.Set(&err)
+	ec.Do(
+		s.StartElem(xmlwriter.Elem{Prefix: "saml", URI: samlNS, Name: "Assertion"}),
+		s.WriteAttr(xmlwriter.Attr{Name: "ID", Value: "assertion-1"}),
+		s.WriteElem(xmlwriter.Elem{Prefix: "saml", Name: "Issuer",
+			Content: []xmlwriter.Writable{xmlwriter.Text("me")}}),
+		s.WriteSignature(),
+		s.EndElem(),
+		w.Flush(),
+	)
+
+If WriteSignature is called while the signed element is the current element,
+an enveloped signature is written as its last child, and nothing else may be
+written to the signed element afterwards. If WriteSignature is called after
+the signed element has ended, a detached signature is written wherever the
+writer happens to be.
+
+Canonicalization works from the values passed to the Signer, so anything
+written directly to the underlying Writer inside the signed element is not
+covered by the digest. Indenting injects whitespace into the signed content,
+so writers with an Indenter are not supported.
+*/
+package dsig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+
+	"github.com/shabbyrobe/xmlwriter"
+)
+
+// Algorithm identifiers used in the SignedInfo element.
+const (
+	Namespace      = "http://www.w3.org/2000/09/xmldsig#"
+	AlgExcC14N     = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	AlgEnveloped   = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	AlgSHA256      = "http://www.w3.org/2001/04/xmlenc#sha256"
+	AlgRSASHA256   = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	AlgECDSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+)
+
+// Signer computes the digest of an element identified by ID as it is
+// written, then writes a ds:Signature for it.
+type Signer struct {
+	// Prefix used for the Signature element and its descendants. Defaults
+	// to "ds". If empty, the signature namespace is declared as the default
+	// namespace of the Signature element.
+	Prefix string
+
+	// Name of the attribute that identifies the signed element. Defaults
+	// to "ID", as used by SAML. May be qualified, i.e. "wsu:Id".
+	IDAttr string
+
+	// DER encoded X.509 certificate. If set, it is written into a KeyInfo
+	// element following the SignatureValue.
+	Certificate []byte
+
+	// Source of entropy for the signature. Defaults to crypto/rand.Reader.
+	Rand io.Reader
+
+	w      *xmlwriter.Writer
+	key    crypto.Signer
+	method string
+	id     string
+	digest hash.Hash
+	canon  *canonicalizer
+	signed bool
+}
+
+// NewSigner creates a Signer that will sign the element written through it
+// whose ID attribute matches id. The key must be an RSA or ECDSA private key
+// (*rsa.PrivateKey and *ecdsa.PrivateKey both satisfy crypto.Signer).
+func NewSigner(w *xmlwriter.Writer, key crypto.Signer, id string) (*Signer, error) {
+	if id == "" {
+		return nil, fmt.Errorf("dsig: id must not be empty")
+	}
+	if w.Indenter != nil {
+		return nil, fmt.Errorf("dsig: writers with an Indenter can not be signed")
+	}
+
+	var method string
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		method = AlgRSASHA256
+	case *ecdsa.PublicKey:
+		method = AlgECDSASHA256
+	default:
+		return nil, fmt.Errorf("dsig: unsupported key type %T", key.Public())
+	}
+
+	s := &Signer{
+		Prefix: "ds",
+		IDAttr: "ID",
+		Rand:   rand.Reader,
+		w:      w,
+		key:    key,
+		method: method,
+		id:     id,
+		digest: sha256.New(),
+	}
+	s.canon = newCanonicalizer(s.digest, "", id)
+	return s, nil
+}
+
+// Writer returns the underlying Writer. Content written to it directly is
+// not seen by the Signer.
+func (s *Signer) Writer() *xmlwriter.Writer { return s.w }
+
+// StartElem starts an element, as with Writer.StartElem. Any Attrs and
+// Content assigned to the Elem are written immediately.
+func (s *Signer) StartElem(elem xmlwriter.Elem) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	attrs, content := elem.Attrs, elem.Content
+	elem.Attrs, elem.Content = nil, nil
+	if err := s.w.StartElem(elem); err != nil {
+		return err
+	}
+	if err := s.canon.startElem(elem.Prefix, elem.URI, elem.Name); err != nil {
+		return err
+	}
+	if err := s.WriteAttr(attrs...); err != nil {
+		return err
+	}
+	for _, c := range content {
+		var err error
+		switch t := c.(type) {
+		case xmlwriter.Text:
+			err = s.WriteText(string(t))
+		case xmlwriter.Elem:
+			err = s.WriteElem(t)
+		case xmlwriter.Comment:
+			err = s.WriteComment(t)
+		case xmlwriter.CData:
+			err = s.WriteCData(t)
+		default:
+			err = fmt.Errorf("dsig: unexpected child of element")
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteElem writes a complete element, as with Writer.WriteElem.
+func (s *Signer) WriteElem(elem xmlwriter.Elem) error {
+	if err := s.StartElem(elem); err != nil {
+		return err
+	}
+	return s.EndElem()
+}
+
+// WriteAttr writes attributes to the current element, as with
+// Writer.WriteAttr.
+func (s *Signer) WriteAttr(attrs ...xmlwriter.Attr) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	for _, a := range attrs {
+		if err := s.w.WriteAttr(a); err != nil {
+			return err
+		}
+		s.canon.idAttr = s.IDAttr
+		if err := s.canon.attr(a.Prefix, a.URI, a.Name, a.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteText writes a text node, as with Writer.WriteText.
+func (s *Signer) WriteText(text string) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.w.WriteText(text); err != nil {
+		return err
+	}
+	return s.canon.text(text)
+}
+
+// WriteCData writes a complete CData section, as with Writer.WriteCData.
+// The canonical form of a CData section is its content as escaped text.
+func (s *Signer) WriteCData(cdata xmlwriter.CData) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.w.WriteCData(cdata); err != nil {
+		return err
+	}
+	return s.canon.text(cdata.Content)
+}
+
+// WriteComment writes a complete comment, as with Writer.WriteComment.
+// Comments are excluded from the digest.
+func (s *Signer) WriteComment(comment xmlwriter.Comment) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.w.WriteComment(comment); err != nil {
+		return err
+	}
+	return s.canon.comment()
+}
+
+// WritePI writes a processing instruction, as with Writer.WritePI.
+func (s *Signer) WritePI(pi xmlwriter.PI) error {
+	if err := s.checkWritable(); err != nil {
+		return err
+	}
+	if err := s.w.WritePI(pi); err != nil {
+		return err
+	}
+	return s.canon.pi(pi.Target, pi.Content)
+}
+
+// EndElem ends the current element, as with Writer.EndElem.
+func (s *Signer) EndElem(name ...string) error {
+	if err := s.w.EndElem(name...); err != nil {
+		return err
+	}
+	return s.canon.endElem()
+}
+
+// DigestValue returns the SHA-256 digest of the canonicalized signed
+// element. It is only available once WriteSignature has been called.
+func (s *Signer) DigestValue() []byte {
+	if !s.signed {
+		return nil
+	}
+	return s.digest.Sum(nil)
+}
+
+// WriteSignature writes a ds:Signature element referencing the signed
+// element. See the package documentation for where the signature can be
+// placed.
+func (s *Signer) WriteSignature() error {
+	if s.signed {
+		return fmt.Errorf("dsig: signature already written")
+	}
+	if s.w.Indenter != nil {
+		return fmt.Errorf("dsig: writers with an Indenter can not be signed")
+	}
+
+	enveloped := false
+	if !s.canon.done {
+		if err := s.canon.seal(); err != nil {
+			return fmt.Errorf("dsig: element with %s=%q has not been written: %w", s.IDAttr, s.id, err)
+		}
+		enveloped = true
+	} else if err := s.canon.comment(); err != nil {
+		// the signature may be a child of an element whose start tag has
+		// not been flushed yet.
+		return err
+	}
+	s.signed = true
+	digest := s.digest.Sum(nil)
+
+	sigElem := xmlwriter.Elem{Prefix: s.Prefix, URI: Namespace, Name: "Signature"}
+	if s.Prefix == "" {
+		sigElem.Attrs = []xmlwriter.Attr{{Name: "xmlns", Value: Namespace}}
+	}
+	if err := s.w.StartElem(sigElem); err != nil {
+		return err
+	}
+
+	// SignedInfo is canonicalized on its own, with the Signature element's
+	// namespace declaration in scope:
+	siHash := sha256.New()
+	si := newCanonicalizer(siHash, "", "")
+	si.declare(s.Prefix, Namespace)
+	if err := s.writeTree(si, s.signedInfo(digest, enveloped)); err != nil {
+		return err
+	}
+
+	sig, err := s.sign(siHash.Sum(nil))
+	if err != nil {
+		return err
+	}
+
+	tail := []xmlwriter.Elem{s.elem("SignatureValue", xmlwriter.Text(base64.StdEncoding.EncodeToString(sig)))}
+	if len(s.Certificate) > 0 {
+		tail = append(tail, s.elem("KeyInfo",
+			s.elem("X509Data",
+				s.elem("X509Certificate", xmlwriter.Text(base64.StdEncoding.EncodeToString(s.Certificate))))))
+	}
+	for _, e := range tail {
+		if err := s.w.WriteElem(e); err != nil {
+			return err
+		}
+	}
+	return s.w.EndElem()
+}
+
+func (s *Signer) checkWritable() error {
+	if s.canon.sealed {
+		return fmt.Errorf("dsig: signed element may not be modified after the signature is written")
+	}
+	return nil
+}
+
+func (s *Signer) elem(name string, content ...xmlwriter.Writable) xmlwriter.Elem {
+	return xmlwriter.Elem{Prefix: s.Prefix, Name: name, Content: content}
+}
+
+func (s *Signer) alg(name, alg string, content ...xmlwriter.Writable) xmlwriter.Elem {
+	e := s.elem(name, content...)
+	e.Attrs = []xmlwriter.Attr{{Name: "Algorithm", Value: alg}}
+	return e
+}
+
+func (s *Signer) signedInfo(digest []byte, enveloped bool) xmlwriter.Elem {
+	var transforms []xmlwriter.Writable
+	if enveloped {
+		transforms = append(transforms, s.alg("Transform", AlgEnveloped))
+	}
+	transforms = append(transforms, s.alg("Transform", AlgExcC14N))
+
+	ref := s.elem("Reference",
+		s.elem("Transforms", transforms...),
+		s.alg("DigestMethod", AlgSHA256),
+		s.elem("DigestValue", xmlwriter.Text(base64.StdEncoding.EncodeToString(digest))),
+	)
+	ref.Attrs = []xmlwriter.Attr{{Name: "URI", Value: "#" + s.id}}
+
+	return s.elem("SignedInfo",
+		s.alg("CanonicalizationMethod", AlgExcC14N),
+		s.alg("SignatureMethod", s.method),
+		ref,
+	)
+}
+
+// writeTree writes an element to both the writer and a canonicalizer.
+// Only the node types used in SignedInfo are supported.
+func (s *Signer) writeTree(c *canonicalizer, e xmlwriter.Elem) error {
+	attrs, content := e.Attrs, e.Content
+	e.Attrs, e.Content = nil, nil
+	if err := s.w.StartElem(e); err != nil {
+		return err
+	}
+	if err := c.startElem(e.Prefix, e.URI, e.Name); err != nil {
+		return err
+	}
+	for _, a := range attrs {
+		if err := s.w.WriteAttr(a); err != nil {
+			return err
+		}
+		if err := c.attr(a.Prefix, a.URI, a.Name, a.Value); err != nil {
+			return err
+		}
+	}
+	for _, n := range content {
+		switch t := n.(type) {
+		case xmlwriter.Text:
+			if err := s.w.WriteText(string(t)); err != nil {
+				return err
+			}
+			if err := c.text(string(t)); err != nil {
+				return err
+			}
+		case xmlwriter.Elem:
+			if err := s.writeTree(c, t); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("dsig: unexpected node %T in signature", n)
+		}
+	}
+	if err := s.w.EndElem(); err != nil {
+		return err
+	}
+	return c.endElem()
+}
+
+func (s *Signer) sign(digest []byte) ([]byte, error) {
+	sig, err := s.key.Sign(s.Rand, digest, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := s.key.Public().(*ecdsa.PublicKey)
+	if !ok {
+		return sig, nil
+	}
+
+	// crypto.Signer produces ASN.1 encoded ECDSA signatures, but XML-DSig
+	// wants the fixed width concatenation of r and s:
+	// https://www.w3.org/TR/xmldsig-core1/#sec-ECDSA
+	var rs struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(sig, &rs); err != nil {
+		return nil, err
+	}
+	size := (pub.Curve.Params().BitSize + 7) / 8
+	out := make([]byte, 2*size)
+	rb, sb := rs.R.Bytes(), rs.S.Bytes()
+	copy(out[size-len(rb):size], rb)
+	copy(out[2*size-len(sb):], sb)
+	return out, nil
+}
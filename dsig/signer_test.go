@@ -0,0 +1,183 @@
+package dsig
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/shabbyrobe/xmlwriter"
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+)
+
+const testNS = "urn:test"
+
+func writeSigned(t *testing.T, key crypto.Signer, enveloped bool) (*Signer, string) {
+	t.Helper()
+	b := &bytes.Buffer{}
+	w := xmlwriter.Open(b)
+	s, err := NewSigner(w, key, "item-1")
+	tt.OK(t, err)
+
+	ec := &xmlwriter.ErrCollector{}
+	ec.Must(
+		s.StartElem(xmlwriter.Elem{Prefix: "t", URI: testNS, Name: "root"}),
+		s.StartElem(xmlwriter.Elem{Prefix: "t", Name: "item"}),
+		s.WriteAttr(
+			xmlwriter.Attr{Name: "b", Value: "2"},
+			xmlwriter.Attr{Prefix: "t", Name: "c", Value: "1"},
+			xmlwriter.Attr{Name: "ID", Value: "item-1"},
+		),
+		s.WriteComment(xmlwriter.Comment{Content: "ignored"}),
+		s.WriteText(`a & "b"`),
+		s.WriteElem(xmlwriter.Elem{Name: "empty"}),
+		s.WriteCData(xmlwriter.CData{Content: "<c>"}),
+	)
+	if enveloped {
+		ec.Must(s.WriteSignature(), s.EndElem())
+	} else {
+		ec.Must(s.EndElem(), s.WriteSignature())
+	}
+	ec.Must(s.EndElem(), w.Flush())
+	return s, b.String()
+}
+
+const testCanonicalItem = `<t:item xmlns:t="urn:test" ID="item-1" b="2" t:c="1">` +
+	`a &amp; "b"<empty></empty>&lt;c&gt;</t:item>`
+
+func canonicalSignedInfo(method, digest string, enveloped bool) string {
+	transforms := `<ds:Transform Algorithm="` + AlgExcC14N + `"></ds:Transform>`
+	if enveloped {
+		transforms = `<ds:Transform Algorithm="` + AlgEnveloped + `"></ds:Transform>` + transforms
+	}
+	return `<ds:SignedInfo xmlns:ds="` + Namespace + `">` +
+		`<ds:CanonicalizationMethod Algorithm="` + AlgExcC14N + `"></ds:CanonicalizationMethod>` +
+		`<ds:SignatureMethod Algorithm="` + method + `"></ds:SignatureMethod>` +
+		`<ds:Reference URI="#item-1"><ds:Transforms>` + transforms + `</ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="` + AlgSHA256 + `"></ds:DigestMethod>` +
+		`<ds:DigestValue>` + digest + `</ds:DigestValue></ds:Reference></ds:SignedInfo>`
+}
+
+var signatureValue = regexp.MustCompile(`<ds:SignatureValue>([^<]*)</ds:SignatureValue>`)
+
+func TestSignRSAEnveloped(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	tt.OK(t, err)
+
+	s, out := writeSigned(t, key, true)
+
+	sum := sha256.Sum256([]byte(testCanonicalItem))
+	tt.Equals(t, sum[:], s.DigestValue())
+	digest := base64.StdEncoding.EncodeToString(sum[:])
+
+	tt.Assert(t, strings.HasPrefix(out, `<t:root xmlns:t="urn:test"><t:item b="2" t:c="1" ID="item-1">`), out)
+	tt.Assert(t, strings.HasSuffix(out, `</ds:Signature></t:item></t:root>`), out)
+	tt.Assert(t, strings.Contains(out, `<ds:Signature xmlns:ds="`+Namespace+`"><ds:SignedInfo>`), out)
+
+	m := signatureValue.FindStringSubmatch(out)
+	tt.Assert(t, m != nil, out)
+	sig, err := base64.StdEncoding.DecodeString(m[1])
+	tt.OK(t, err)
+
+	si := sha256.Sum256([]byte(canonicalSignedInfo(AlgRSASHA256, digest, true)))
+	tt.OK(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, si[:], sig))
+}
+
+func TestSignECDSADetached(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tt.OK(t, err)
+
+	s, out := writeSigned(t, key, false)
+
+	sum := sha256.Sum256([]byte(testCanonicalItem))
+	tt.Equals(t, sum[:], s.DigestValue())
+	digest := base64.StdEncoding.EncodeToString(sum[:])
+	tt.Assert(t, strings.Contains(out, `</t:item><ds:Signature`), out)
+
+	m := signatureValue.FindStringSubmatch(out)
+	tt.Assert(t, m != nil, out)
+	sig, err := base64.StdEncoding.DecodeString(m[1])
+	tt.OK(t, err)
+	tt.Equals(t, 64, len(sig))
+
+	si := sha256.Sum256([]byte(canonicalSignedInfo(AlgECDSASHA256, digest, false)))
+	r, ss := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	tt.Assert(t, ecdsa.Verify(&key.PublicKey, si[:], r, ss))
+}
+
+func TestCanonicalNamespaces(t *testing.T) {
+	for idx, tc := range []struct {
+		write func(s *Signer) error
+		out   string
+	}{
+		{func(s *Signer) error {
+			// the default namespace is only rendered where it is used, and
+			// unused prefixes are dropped:
+			ec := &xmlwriter.ErrCollector{}
+			ec.Do(
+				s.StartElem(xmlwriter.Elem{Name: "root", Attrs: []xmlwriter.Attr{
+					{Name: "xmlns", Value: "urn:default"},
+					{Prefix: "xmlns", Name: "unused", Value: "urn:unused"},
+					{Name: "ID", Value: "x"},
+				}}),
+				s.WriteElem(xmlwriter.Elem{Name: "child"}),
+				s.EndElem(),
+			)
+			return ec.Err
+		}, `<root xmlns="urn:default" ID="x"><child></child></root>`},
+
+		{func(s *Signer) error {
+			// xml:* attributes never need a declaration, and attributes are
+			// sorted by namespace URI:
+			ec := &xmlwriter.ErrCollector{}
+			ec.Do(
+				s.StartElem(xmlwriter.Elem{Name: "root", Attrs: []xmlwriter.Attr{
+					{Prefix: "z", URI: "urn:a", Name: "a", Value: "1"},
+					{Prefix: "xml", Name: "lang", Value: "en"},
+					{Name: "ID", Value: "x"},
+				}}),
+				s.WriteText("line\r\nbreak"),
+				s.EndElem(),
+			)
+			return ec.Err
+		}, `<root xmlns:z="urn:a" ID="x" xml:lang="en" z:a="1">line` + "\n" + `break</root>`},
+	} {
+		t.Run(fmt.Sprintf("%d", idx), func(t *testing.T) {
+			var b, c bytes.Buffer
+			s, err := NewSigner(xmlwriter.Open(&b), &rsa.PrivateKey{}, "x")
+			tt.OK(t, err)
+			s.canon.out = &c
+			tt.OK(t, tc.write(s))
+			tt.Equals(t, tc.out, c.String())
+		})
+	}
+}
+
+func TestSignErrors(t *testing.T) {
+	key := &rsa.PrivateKey{}
+
+	_, err := NewSigner(xmlwriter.Open(&bytes.Buffer{}, xmlwriter.WithIndent()), key, "x")
+	tt.Pattern(t, `Indenter`, err.Error())
+
+	s, err := NewSigner(xmlwriter.Open(&bytes.Buffer{}), key, "x")
+	tt.OK(t, err)
+	tt.OK(t, s.StartElem(xmlwriter.Elem{Name: "root"}))
+	tt.Pattern(t, `has not been written`, s.WriteSignature().Error())
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tt.OK(t, err)
+	s, err = NewSigner(xmlwriter.Open(&bytes.Buffer{}), ecKey, "x")
+	tt.OK(t, err)
+	tt.OK(t, s.StartElem(xmlwriter.Elem{Name: "root", Attrs: []xmlwriter.Attr{{Name: "ID", Value: "x"}}}))
+	tt.OK(t, s.WriteSignature())
+	tt.Pattern(t, `may not be modified`, s.WriteAttr(xmlwriter.Attr{Name: "late"}).Error())
+}
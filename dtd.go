@@ -30,7 +30,7 @@ func (d DTD) open(n *node, w *Writer) error {
 		if len(d.Name) == 0 {
 			return fmt.Errorf("xmlwriter: DTD name must not be empty")
 		}
-		if err := CheckName(d.Name); err != nil {
+		if err := w.printer.names.check(d.Name); err != nil {
 			return err
 		}
 	}
@@ -98,7 +98,7 @@ func (d DTDElem) write(w *Writer) error {
 		if len(d.Decl) == 0 {
 			return fmt.Errorf("xmlwriter: ELEMENT decl must not be empty")
 		}
-		if err := CheckName(d.Name); err != nil {
+		if err := w.printer.names.check(d.Name); err != nil {
 			return err
 		}
 	}
@@ -111,8 +111,8 @@ func (d DTDElem) write(w *Writer) error {
 	w.printer.WriteByte(' ')
 	w.printer.WriteString(d.Decl)
 	w.printer.WriteString(">")
-	if w.Indenter != nil {
-		w.last = Event{StateEnded, DTDElemNode, 0}
+	if err := w.endLeaf(DTDElemNode); err != nil {
+		return err
 	}
 	return w.printer.cachedWriteError()
 }
@@ -151,7 +151,7 @@ func (d DTDEntity) write(w *Writer) error {
 		if len(d.Name) == 0 {
 			return fmt.Errorf("xmlwriter: ENTITY name must not be empty")
 		}
-		if err := CheckName(d.Name); err != nil {
+		if err := w.printer.names.check(d.Name); err != nil {
 			return err
 		}
 		if err := w.checkParent(noNodeFlag | dtdNodeFlag); err != nil {
@@ -182,7 +182,7 @@ func (d DTDEntity) write(w *Writer) error {
 			if !d.IsPE {
 				w.printer.WriteString(" NDATA ")
 				if w.Enforce {
-					if err := CheckName(d.NDataID); err != nil {
+					if err := w.printer.names.check(d.NDataID); err != nil {
 						return err
 					}
 				}
@@ -205,8 +205,8 @@ func (d DTDEntity) write(w *Writer) error {
 	}
 
 	w.printer.WriteString(">")
-	if w.Indenter != nil {
-		w.last = Event{StateEnded, DTDEntityNode, 0}
+	if err := w.endLeaf(DTDEntityNode); err != nil {
+		return err
 	}
 	return w.printer.cachedWriteError()
 }
@@ -255,7 +255,7 @@ func (d DTDAttList) open(n *node, w *Writer) error {
 		if len(d.Name) == 0 {
 			return fmt.Errorf("xmlwriter: DTD attlist name must not be empty")
 		}
-		if err := CheckName(d.Name); err != nil {
+		if err := w.printer.names.check(d.Name); err != nil {
 			return err
 		}
 	}
@@ -342,7 +342,7 @@ func (d DTDAttr) write(w *Writer) error {
 		if len(d.Type) == 0 {
 			return fmt.Errorf("xmlwriter: DTD attr type must not be empty")
 		}
-		if err := CheckName(d.Name); err != nil {
+		if err := w.printer.names.check(d.Name); err != nil {
 			return err
 		}
 	}
@@ -397,8 +397,8 @@ func (d DTDAttr) write(w *Writer) error {
 		return fmt.Errorf("xmlwriter: unknown DTDAttr default type")
 	}
 
-	if w.Indenter != nil {
-		w.last = Event{StateEnded, DTDAttrNode, 0}
+	if err := w.endLeaf(DTDAttrNode); err != nil {
+		return err
 	}
 
 	return w.printer.cachedWriteError()
@@ -422,7 +422,7 @@ func (n Notation) write(w *Writer) error {
 		if len(n.Name) == 0 {
 			return fmt.Errorf("xmlwriter: NOTATION name must not be empty")
 		}
-		if err := CheckName(n.Name); err != nil {
+		if err := w.printer.names.check(n.Name); err != nil {
 			return err
 		}
 		if len(n.PublicID) == 0 && len(n.SystemID) == 0 {
@@ -446,8 +446,8 @@ func (n Notation) write(w *Writer) error {
 		}
 	}
 	w.printer.WriteString(">")
-	if w.Indenter != nil {
-		w.last = Event{StateEnded, NotationNode, 0}
+	if err := w.endLeaf(NotationNode); err != nil {
+		return err
 	}
 	return w.printer.cachedWriteError()
 }
@@ -66,7 +66,7 @@ func (e Elem) open(n *node, w *Writer) error {
 		if name == "" {
 			return fmt.Errorf("xmlwriter: element name must not be empty")
 		}
-		if err := CheckName(name); err != nil {
+		if err := w.printer.names.check(name); err != nil {
 			return err
 		}
 	}
@@ -78,7 +78,7 @@ func (e Elem) open(n *node, w *Writer) error {
 	w.printer.WriteByte('<')
 	w.printer.WriteString(name)
 
-	if e.Prefix != "" && e.URI != "" {
+	if e.Prefix != "" && e.URI != "" && !w.bufferAttrs() {
 		// we can assume the prefix has been enforced already by open running
 		// CheckName on elem.fullName()
 		n.elem.namespaces[0].written = true
@@ -100,15 +100,29 @@ func (e Elem) opened(n *node, w *Writer, prev NodeState) error {
 	if len(e.namespaces) > 0 {
 		for i, ns := range e.namespaces {
 			if ns.written == false {
-				if err := w.printer.printAttr("xmlns:"+ns.prefix, ns.uri, w.Enforce); err != nil {
+				if w.bufferAttrs() {
+					// Written along with the other buffered attributes:
+					w.attrs = append(w.attrs, Attr{Prefix: "xmlns", Name: ns.prefix, Value: ns.uri})
+				} else if err := w.printer.printAttr("xmlns:"+ns.prefix, ns.uri, w.Enforce); err != nil {
 					return err
 				}
 				n.elem.namespaces[i].written = true
 			}
 		}
 	}
+	if len(w.attrs) > 0 {
+		if err := w.flushAttrs(); err != nil {
+			return err
+		}
+	}
 
-	if n.children == 0 && !e.Full && len(e.Content) == 0 {
+	empty := n.children == 0 && !e.Full && len(e.Content) == 0
+	if ti, ok := w.Indenter.(tagIndenter); ok {
+		if err := ti.indentTag(w, empty); err != nil {
+			return err
+		}
+	}
+	if empty {
 		w.printer.WriteString("/>")
 	} else {
 		w.printer.WriteByte('>')
@@ -125,6 +139,8 @@ func (e Elem) opened(n *node, w *Writer, prev NodeState) error {
 				err = w.WriteComment(t)
 			case CData:
 				err = w.WriteCData(t)
+			case TextHole:
+				err = w.Write(t)
 			default:
 				return fmt.Errorf("xmlwriter: unexpected child of element")
 			}
@@ -141,6 +157,11 @@ func (e Elem) end(n *node, w *Writer, prev NodeState) error {
 	if prev != StateOpen || e.Full || n.children > 0 {
 		w.printer.WriteString("</")
 		w.printer.WriteString(e.fullName())
+		if ti, ok := w.Indenter.(tagIndenter); ok {
+			if err := ti.indentTag(w, false); err != nil {
+				return err
+			}
+		}
 		w.printer.WriteByte('>')
 	}
 	return w.printer.cachedWriteError()
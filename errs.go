@@ -48,6 +48,10 @@ For functions that return an error:
 If you want to panic instead, just substitute `defer ec.Set(&err)` with `defer
 ec.Panic()`
 
+This is safe to do with a Writer: once one of its methods has failed, every
+subsequent call returns a *FailedError without writing anything, see
+Writer.Err.
+
 It is entirely the responsibility of the library's user to remember to call
 either `ec.Set()` or `ec.Panic()`. If you don't, you'll be swallowing errors.
 */
@@ -137,3 +141,101 @@ func (e *ErrCollector) Must(errs ...error) {
 		}
 	}
 }
+
+// FailedError is returned by every Writer method called after one has already
+// failed, see Writer.Err.
+type FailedError struct {
+	Err error
+}
+
+// Error implements the error interface.
+func (e *FailedError) Error() string {
+	return fmt.Sprintf("xmlwriter: writer has already failed: %v", e.Err)
+}
+
+// Unwrap satisfies the requirement of `errors.Unwrap()`, so the original
+// error can be found with e.g. `errors.Is()` and `errors.As()`.
+func (e *FailedError) Unwrap() error {
+	return e.Err
+}
+
+// Err returns the error which stopped the Writer, or nil.
+//
+// If a method fails after it has started writing output or changing the node
+// stack, the Writer may have written incomplete markup and its node stack can
+// no longer be trusted, so the Writer stops: every subsequent call returns a
+// *FailedError wrapping the error, without writing anything. Flush still
+// writes out whatever was buffered before the failure.
+//
+// A method which fails a check before it has changed anything, like EndElem
+// given the wrong name, returns its error without stopping the Writer. Errors
+// writing to the underlying io.Writer, Limits.MaxBytes and a done context are
+// returned by every subsequent write, rather than as a *FailedError.
+func (w *Writer) Err() error {
+	if w.err != nil {
+		return w.err
+	}
+	return w.printer.cachedWriteError()
+}
+
+// enter is called at the start of every public method which writes to the
+// document, which must defer a call to exit if it succeeds:
+//	if err := w.enter(); err != nil {
+//		return err
+//	}
+//	defer w.exit(&err, "WriteElem", func() string { return debugArg(elem) })
+//
+func (w *Writer) enter() error {
+	if w.err != nil {
+		return w.failed
+	}
+	if w.calls == 0 {
+		w.callStart = w.printer.Offset()
+		w.callDepth = w.current
+		w.callAttrs = len(w.attrs)
+		w.callChildren = w.children()
+	}
+	w.calls++
+	return nil
+}
+
+// exit latches the error returned by the outermost method call if the call
+// had changed anything before it failed, and logs the call if WithDebug is
+// set. Calls made by other calls, like EndElem calling
+// End, are left to the outermost one. args formats the call's arguments; it
+// is only called when logging, and may be nil if there are none.
+func (w *Writer) exit(err *error, method string, args func() string) {
+	w.calls--
+	if w.calls > 0 {
+		return
+	}
+	if *err != nil && *err != w.printer.cachedWriteError() && w.changed() {
+		w.latch(err)
+	}
+	if w.debug != nil {
+		w.trace(method, args, *err)
+	}
+}
+
+// changed reports whether the outermost method call has written anything or
+// changed the node stack since enter.
+func (w *Writer) changed() bool {
+	return w.printer.Offset() != w.callStart ||
+		w.current != w.callDepth ||
+		len(w.attrs) != w.callAttrs ||
+		w.children() != w.callChildren
+}
+
+func (w *Writer) children() int {
+	if w.current < 0 {
+		return 0
+	}
+	return w.nodes[w.current].children
+}
+
+func (w *Writer) latch(err *error) {
+	if *err != nil && w.err == nil {
+		w.err = *err
+		w.failed = &FailedError{Err: *err}
+	}
+}
@@ -73,3 +73,42 @@ func TestCollectorUnwrap(t *testing.T) {
 	}()
 	tt.Assert(t, errors.Is(result, in))
 }
+
+func TestWriterErr(t *testing.T) {
+	b, w := open()
+	tt.OK(t, w.Err())
+	tt.OK(t, w.Start(Elem{Name: "foo"}))
+
+	// The bad attribute name fails after "<bar" has been written:
+	first := w.Start(Elem{Name: "bar", Attrs: []Attr{{Name: "a b"}}})
+	tt.Assert(t, first != nil)
+	tt.Equals(t, first, w.Err())
+
+	err := w.Write(Text("yep"))
+	var ferr *FailedError
+	tt.Assert(t, errors.As(err, &ferr))
+	tt.Equals(t, first, ferr.Err)
+	tt.Assert(t, errors.Is(err, first))
+	tt.Equals(t, err, w.EndAll())
+	tt.Equals(t, err, w.Flush())
+	tt.Equals(t, first, w.Err())
+	tt.Equals(t, "<foo><bar", b.String())
+}
+
+func TestWriterErrCollector(t *testing.T) {
+	b, w := open()
+	ec := &ErrCollector{}
+	ec.Do(
+		w.Start(Elem{Name: "foo"}),
+		w.End(ElemNode, "nope"),
+		w.Write(Text("yep")),
+		w.EndAllFlush(),
+	)
+	tt.Equals(t, 2, ec.Index)
+	tt.Pattern(t, `did not match expected "nope"`, ec.Err.Error())
+
+	// The wrong name is caught before anything is changed, so the Writer
+	// carries on:
+	tt.OK(t, w.Err())
+	tt.Equals(t, "<foo>yep</foo>", b.String())
+}
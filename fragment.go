@@ -0,0 +1,335 @@
+package xmlwriter
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Fragment is a piece of XML which is validated and escaped once, when it
+// is compiled by NewFragment, then written as many times as needed using
+// Writer.WriteFragment. It can contain named holes for text and attribute
+// values which are filled in each time it is written.
+//
+// A Fragment must end every node it starts, and may contain elements, text,
+// comments, CData, processing instructions and raw nodes at the top level.
+// Namespace prefixes it uses without declaring them must be declared by the
+// node it is written into, which WriteFragment checks if Enforce is set.
+type Fragment struct {
+	out   []byte
+	parts []fragmentPart
+	holes []fragmentHole
+	names []string
+
+	// namespace prefixes used but not declared by the Fragment
+	prefixes []string
+
+	// where the Fragment can be written, and what is in it
+	parents nodeFlag
+	stats   Stats
+}
+
+// fragmentPart is a top-level node in a Fragment, written by
+// Writer.WriteFragment as though it were a leaf node.
+type fragmentPart struct {
+	kind         NodeKind
+	prefix, name string
+	start, end   int
+}
+
+type fragmentHole struct {
+	// offset of the hole in Fragment.out, the index of the part it is in,
+	// and the index of its name in Fragment.names.
+	at   int
+	part int
+	name int
+	attr bool
+}
+
+// TextHole is a named hole in a Fragment, which is filled with escaped text
+// when the Fragment is written. It may only be written to the Writer passed
+// to the function given to NewFragment.
+type TextHole string
+
+func (t TextHole) kind() NodeKind { return TextNode }
+
+func (t TextHole) write(w *Writer) error {
+	if w.fragment == nil {
+		return fmt.Errorf("xmlwriter: text hole %q written outside a fragment", string(t))
+	}
+	if err := w.checkParent(noNodeFlag | elemNodeFlag); err != nil {
+		return err
+	}
+	if err := w.writeBeginNext(TextNode); err != nil {
+		return err
+	}
+	w.stats.Texts++
+	w.fragment.hole(string(t), false, w.printer.Offset())
+	return w.endLeaf(TextNode)
+}
+
+// AttrHole is a named hole in a Fragment, which is filled with an escaped
+// attribute value when the Fragment is written. Like TextHole, it may only be
+// written while compiling a Fragment. Prefix must be declared by the element
+// or one of its parents.
+type AttrHole struct {
+	Prefix string
+	Name   string
+	Hole   string
+}
+
+func (a AttrHole) kind() NodeKind { return AttrNode }
+
+func (a AttrHole) write(w *Writer) error {
+	if w.fragment == nil {
+		return fmt.Errorf("xmlwriter: attr hole %q written outside a fragment", a.Hole)
+	}
+	if err := w.checkParent(elemNodeFlag); err != nil {
+		return err
+	}
+	if w.nodes[w.current].state != StateOpen {
+		return fmt.Errorf("xmlwriter: attr hole %q written after element content", a.Hole)
+	}
+	name := a.Name
+	if a.Prefix != "" {
+		name = a.Prefix + ":" + name
+	}
+	if err := w.printer.names.check(name); err != nil {
+		return err
+	}
+	if a.Prefix != "" {
+		w.fragment.usePrefix(a.Prefix, w.lookupNS(a.Prefix))
+	}
+	w.nodes[w.current].attrs++
+	w.stats.Attrs++
+
+	w.printer.WriteByte(' ')
+	w.printer.WriteString(name)
+	w.printer.WriteString(`="`)
+	w.fragment.hole(a.Hole, true, w.printer.Offset())
+	w.printer.WriteByte('"')
+	return w.printer.cachedWriteError()
+}
+
+// NewFragment compiles a Fragment from the nodes written by build. The
+// Writer passed to build has the default options and is only valid until
+// build returns. TextHole and AttrHole are used to leave holes in it:
+//	f, err := xmlwriter.NewFragment(func(w *xmlwriter.Writer) error {
+//		return w.Block(xmlwriter.Elem{Name: "entry"},
+//			xmlwriter.AttrHole{Name: "id", Hole: "id"},
+//			xmlwriter.Elem{Name: "title", Content: []xmlwriter.Writable{
+//				xmlwriter.TextHole("title"),
+//			}},
+//		)
+//	})
+//
+func NewFragment(build func(w *Writer) error) (*Fragment, error) {
+	var buf bytes.Buffer
+	f := &Fragment{parents: ^nodeFlag(0)}
+	w := Open(&buf, WithListener(ListenerFunc(f.record)))
+	w.fragment = f
+	if err := build(w); err != nil {
+		return nil, err
+	}
+	if w.current >= 0 {
+		return nil, fmt.Errorf("xmlwriter: fragment did not end %s node", w.nodes[w.current].kind.Name())
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	f.out = buf.Bytes()
+	f.stats = w.Stats()
+	return f, nil
+}
+
+// record is the Listener which finds the Fragment's top-level nodes while it
+// is compiled.
+func (f *Fragment) record(w *Writer, ev Event) error {
+	if ev.State == StateOpen && ev.Prefix != "" {
+		f.usePrefix(ev.Prefix, ev.URI)
+	}
+	if ev.Depth != 0 || ev.Node == AttrNode {
+		return nil
+	}
+	switch ev.State {
+	case StateOpen:
+		var parents nodeFlag
+		switch ev.Node {
+		case ElemNode, PINode:
+			parents = noNodeFlag | docNodeFlag | elemNodeFlag
+		case TextNode, CDataNode:
+			parents = noNodeFlag | elemNodeFlag
+		case CommentNode:
+			parents = noNodeFlag | docNodeFlag | dtdNodeFlag | elemNodeFlag
+		case RawNode:
+			parents = ^nodeFlag(0)
+		default:
+			return fmt.Errorf("xmlwriter: fragment may not contain %s node", ev.Node.Name())
+		}
+		f.parents &= parents
+		f.parts = append(f.parts, fragmentPart{
+			kind:   ev.Node,
+			prefix: ev.Prefix,
+			name:   ev.Name,
+			start:  int(w.printer.Offset()),
+		})
+	case StateEnded:
+		f.parts[len(f.parts)-1].end = int(w.printer.Offset())
+	}
+	return nil
+}
+
+// usePrefix records prefix if it is used by a node in the Fragment without
+// being declared, in which case uri is empty.
+func (f *Fragment) usePrefix(prefix, uri string) {
+	if uri != "" || prefix == "xmlns" {
+		return
+	}
+	for _, p := range f.prefixes {
+		if p == prefix {
+			return
+		}
+	}
+	f.prefixes = append(f.prefixes, prefix)
+}
+
+func (f *Fragment) hole(name string, attr bool, at int64) {
+	idx := -1
+	for i, n := range f.names {
+		if n == name {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		idx = len(f.names)
+		f.names = append(f.names, name)
+	}
+	f.holes = append(f.holes, fragmentHole{at: int(at), part: len(f.parts) - 1, name: idx, attr: attr})
+}
+
+// Holes returns the names of the Fragment's holes, in the order their values
+// are passed to Writer.WriteFragment. A name used by more than one hole is
+// only listed once, and all of those holes are filled with the same value.
+func (f *Fragment) Holes() []string {
+	return f.names
+}
+
+// WriteFragment writes a Fragment into the current node, filling its holes
+// with values, which must be given in the order returned by
+// Fragment.Holes.
+//
+// Each of the Fragment's top-level nodes is written as a single unit: an
+// Indenter places it, and Listeners see its StateOpen and StateEnded
+// events, but anything inside it is written exactly as it was compiled.
+// Limits.MaxDepth and Limits.MaxTextLen apply to the Fragment's depth and
+// values; the rest of its content was checked when it was compiled.
+func (w *Writer) WriteFragment(f *Fragment, values ...string) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteFragment", func() string { return debugArg(len(values)) + " values" })
+
+	if len(values) != len(f.names) {
+		return fmt.Errorf("xmlwriter: fragment has %d holes, found %d values", len(f.names), len(values))
+	}
+	if w.Enforce {
+		if err := w.checkParent(f.parents); err != nil {
+			return err
+		}
+		for _, prefix := range f.prefixes {
+			if w.lookupNS(prefix) == "" {
+				return fmt.Errorf("xmlwriter: fragment uses undeclared namespace prefix %q", prefix)
+			}
+		}
+	}
+	depth := w.current + 1 + f.stats.MaxDepth
+	if f.stats.Elems > 0 && w.limits.MaxDepth > 0 && depth >= w.limits.MaxDepth {
+		return &LimitError{Limit: "MaxDepth", Max: int64(w.limits.MaxDepth)}
+	}
+	for _, v := range values {
+		if err := w.checkTextLen(len(v)); err != nil {
+			return err
+		}
+	}
+
+	hole := 0
+	for i := range f.parts {
+		p := &f.parts[i]
+		if err := w.beginFragmentPart(p); err != nil {
+			return err
+		}
+		at := p.start
+		for ; hole < len(f.holes) && f.holes[hole].part == i; hole++ {
+			h := f.holes[hole]
+			w.printer.Write(f.out[at:h.at])
+			if h.attr {
+				w.printer.EscapeAttrString(values[h.name])
+			} else {
+				w.printer.EscapeString(values[h.name])
+			}
+			at = h.at
+		}
+		w.printer.Write(f.out[at:p.end])
+		if err := w.printer.cachedWriteError(); err != nil {
+			return err
+		}
+		if err := w.endFragmentPart(p); err != nil {
+			return err
+		}
+	}
+
+	w.stats.Elems += f.stats.Elems
+	w.stats.Attrs += f.stats.Attrs
+	w.stats.Texts += f.stats.Texts
+	w.stats.Comments += f.stats.Comments
+	w.stats.CDatas += f.stats.CDatas
+	if f.stats.Elems > 0 && depth > w.stats.MaxDepth {
+		w.stats.MaxDepth = depth
+	}
+	return nil
+}
+
+func (w *Writer) fragmentEvent(state NodeState, p *fragmentPart) Event {
+	ev := w.leafEvent(state, p.kind)
+	ev.Prefix, ev.Name = p.prefix, p.name
+	if p.prefix != "" {
+		ev.URI = w.lookupNS(p.prefix)
+	}
+	return ev
+}
+
+// beginFragmentPart and endFragmentPart raise the events for a Fragment's
+// top-level node, like writeBeginNext and endLeaf do for leaf nodes.
+func (w *Writer) beginFragmentPart(p *fragmentPart) error {
+	if p.kind != RawNode {
+		if err := w.Next(); err != nil {
+			return err
+		}
+	}
+	if err := w.checkContext(); err != nil {
+		return err
+	}
+	if w.Indenter != nil {
+		if err := w.writeIndent(w.fragmentEvent(StateOpen, p)); err != nil {
+			return err
+		}
+		w.last = w.fragmentEvent(StateEnded, p)
+	}
+	if len(w.listeners) > 0 {
+		w.leafStart = w.printer.Offset()
+		return w.notify(w.fragmentEvent(StateOpen, p))
+	}
+	return nil
+}
+
+func (w *Writer) endFragmentPart(p *fragmentPart) error {
+	if w.Indenter != nil {
+		w.last = w.fragmentEvent(StateEnded, p)
+	}
+	if len(w.listeners) > 0 {
+		ev := w.fragmentEvent(StateEnded, p)
+		ev.Bytes = int(w.printer.Offset() - w.leafStart)
+		return w.notify(ev)
+	}
+	return nil
+}
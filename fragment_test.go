@@ -0,0 +1,162 @@
+package xmlwriter
+
+import (
+	"io/ioutil"
+	"testing"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+)
+
+func entryFragment(t *testing.T) *Fragment {
+	t.Helper()
+	f, err := NewFragment(func(w *Writer) error {
+		ec := &ErrCollector{}
+		ec.Do(
+			w.Start(Elem{Name: "entry"}),
+			w.Write(AttrHole{Name: "id", Hole: "id"}, Attr{Name: "kind", Value: "a&b"}),
+			w.WriteElem(Elem{Name: "title", Content: []Writable{TextHole("title")}}),
+			w.Block(Elem{Name: "ref", Attrs: []Attr{{Name: "fixed"}}}, AttrHole{Name: "to", Hole: "id"}),
+			w.End(ElemNode),
+		)
+		return ec.Unwrap()
+	})
+	tt.OK(t, err)
+	return f
+}
+
+func TestFragment(t *testing.T) {
+	f := entryFragment(t)
+	tt.Equals(t, []string{"id", "title"}, f.Holes())
+
+	b, w := open()
+	ec := &ErrCollector{}
+	ec.Must(
+		w.StartElem(Elem{Name: "feed"}),
+		w.WriteFragment(f, `1"`, "<one>"),
+		w.WriteFragment(f, "2", "two"),
+		w.EndAll(),
+	)
+	tt.Equals(t, `<feed>`+
+		`<entry id="1&#34;" kind="a&amp;b"><title>&lt;one&gt;</title><ref fixed="" to="1&#34;"/></entry>`+
+		`<entry id="2" kind="a&amp;b"><title>two</title><ref fixed="" to="2"/></entry>`+
+		`</feed>`, str(b, w))
+
+	s := w.Stats()
+	tt.Equals(t, 7, s.Elems)
+	tt.Equals(t, 2, s.MaxDepth)
+}
+
+func TestFragmentIndent(t *testing.T) {
+	f, err := NewFragment(func(w *Writer) error {
+		return w.Write(Elem{Name: "a", Content: []Writable{TextHole("x")}}, Comment{Content: "c"})
+	})
+	tt.OK(t, err)
+
+	var log eventLog
+	b, w := open(WithIndent(), WithListener(&log))
+	ec := &ErrCollector{}
+	ec.Must(
+		w.StartElem(Elem{Name: "root"}),
+		w.WriteFragment(f, "1"),
+		w.WriteElem(Elem{Name: "b"}),
+		w.EndAll(),
+	)
+	tt.Equals(t, "<root>\n <a>1</a>\n <!--c-->\n <b/>\n</root>", str(b, w))
+	tt.Equals(t, []string{"open elem a 0", "ended elem a 8"}, []string(log[2:4]))
+}
+
+func TestFragmentTopLevelHoles(t *testing.T) {
+	f, err := NewFragment(func(w *Writer) error {
+		return w.Write(TextHole("a"), TextHole("b"), Raw("<x/>"), TextHole("a"))
+	})
+	tt.OK(t, err)
+
+	b, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "root"}))
+	tt.OK(t, w.WriteFragment(f, "1", "&"))
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, "<root>1&amp;<x/>1</root>", str(b, w))
+}
+
+func TestFragmentNamespaces(t *testing.T) {
+	f, err := NewFragment(func(w *Writer) error {
+		return w.Block(Elem{Prefix: "x", Name: "a"},
+			AttrHole{Prefix: "y", Name: "b", Hole: "b"},
+			Elem{Prefix: "z", URI: "urn:z", Name: "c"},
+		)
+	})
+	tt.OK(t, err)
+
+	_, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "r"}))
+	tt.Pattern(t, `undeclared namespace prefix "x"`, w.WriteFragment(f, "1").Error())
+
+	_, w = open()
+	tt.OK(t, w.StartElem(Elem{Prefix: "x", URI: "urn:x", Name: "r"}))
+	tt.Pattern(t, `undeclared namespace prefix "y"`, w.WriteFragment(f, "1").Error())
+
+	b, w := open()
+	tt.OK(t, w.StartElem(Elem{Prefix: "x", URI: "urn:x", Name: "r", Attrs: []Attr{{Prefix: "y", URI: "urn:y", Name: "q"}}}))
+	tt.OK(t, w.WriteFragment(f, "1"))
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, `<x:r xmlns:x="urn:x" y:q="" xmlns:y="urn:y">`+
+		`<x:a y:b="1"><z:c xmlns:z="urn:z"/></x:a></x:r>`, str(b, w))
+}
+
+func TestFragmentErrors(t *testing.T) {
+	_, err := NewFragment(func(w *Writer) error {
+		return w.StartElem(Elem{Name: "a"})
+	})
+	tt.Pattern(t, `did not end elem`, err.Error())
+
+	_, err = NewFragment(func(w *Writer) error {
+		return w.StartDoc(Doc{})
+	})
+	tt.Pattern(t, `may not contain document`, err.Error())
+
+	_, err = NewFragment(func(w *Writer) error {
+		return w.Block(Elem{Name: "a"}, Text("x"), AttrHole{Name: "b", Hole: "b"})
+	})
+	tt.Pattern(t, `after element content`, err.Error())
+
+	_, w := open()
+	tt.Pattern(t, `outside a fragment`, w.Write(TextHole("x")).Error())
+
+	f, err := NewFragment(func(w *Writer) error {
+		return w.Write(TextHole("x"))
+	})
+	tt.OK(t, err)
+
+	_, w = open()
+	tt.Pattern(t, `1 holes, found 0 values`, w.WriteFragment(f).Error())
+
+	_, w = open()
+	tt.OK(t, w.StartDoc(Doc{}))
+	tt.Pattern(t, `unexpected kind document`, w.WriteFragment(f, "x").Error())
+
+	_, w = open(WithLimits(Limits{MaxTextLen: 2}))
+	tt.OK(t, w.StartElem(Elem{Name: "a"}))
+	tt.Pattern(t, `MaxTextLen`, w.WriteFragment(f, "xyz").Error())
+
+	_, w = open(WithLimits(Limits{MaxDepth: 2}))
+	tt.OK(t, w.StartElem(Elem{Name: "a"}))
+	tt.Pattern(t, `MaxDepth`, w.WriteFragment(entryFragment(t), "1", "2").Error())
+}
+
+func TestAllocsFragment(t *testing.T) {
+	f := entryFragment(t)
+	ec := &ErrCollector{}
+	w := Open(ioutil.Discard)
+
+	_ = allocs()
+
+	before := allocs()
+	ec.Must(w.StartElem(Elem{Name: "foo"}))
+	for i := 0; i < 100; i++ {
+		ec.Must(w.WriteFragment(f, "id", "title"))
+	}
+	ec.Must(w.EndAll())
+	after := allocs()
+	tt.Equals(t, uint64(0), after-before)
+	w.Flush()
+}
@@ -1,5 +1,7 @@
 package xmlwriter
 
+import "unicode/utf8"
+
 // Indenter allows custom indenting strategies to be written for pretty
 // printing the resultant XML.
 //
@@ -20,9 +22,18 @@ type Indenter interface {
 	Wrap(content string) string
 }
 
+// indentResetter is implemented by indenters which keep state between
+// documents, like StandardIndenter. Writer.Reset calls Reset to clear it.
+type indentResetter interface {
+	Reset()
+}
+
 type indentLevel struct {
 	e       Event
 	indents int
+
+	// no whitespace may be added to the content of this level
+	preserve bool
 }
 
 // StandardIndenter implements a primitive Indenter strategy for pretty
@@ -35,8 +46,54 @@ type StandardIndenter struct {
 	// Output whitespace control
 	IndentString string
 
+	// If greater than zero, each attribute of an element with more than
+	// AttrBreak attributes is written on its own line.
+	AttrBreak int
+
+	// If greater than zero, each attribute of an element whose start tag
+	// would end past this column is written on its own line. The width is
+	// measured from the element's indentation, in characters.
+	AttrWidth int
+
+	// When attributes are broken onto their own lines, the first attribute
+	// stays next to the element name and the rest are aligned underneath
+	// it. Otherwise, every attribute is indented one level deeper than the
+	// element.
+	AttrAlign bool
+
+	// Qualified names of elements with whitespace-sensitive content, like
+	// "pre" or "w:t". Nothing is indented inside these elements, as though
+	// they had xml:space="preserve".
+	PreserveElems []string
+
 	depth int
 	stack []indentLevel
+
+	attrIndex int
+	attrBreak bool
+
+	// whether the element being opened preserves whitespace, decided by
+	// PreserveElems and its xml:space attribute.
+	preserve     bool
+	preserveOpen bool
+
+	// set by indenters built on this one to stop whitespace being written
+	// for the next event.
+	suppress bool
+}
+
+// attrBufferer is implemented by indenters which need to see all of an
+// element's attributes before any of them are written. The Writer holds them
+// back until the element is opened, then raises the AttrNode events for each
+// of them in turn while Writer.attrs contains the full list.
+type attrBufferer interface {
+	bufferAttrs() bool
+}
+
+// lineIndenter is implemented by indenters which can indent a new line of
+// content written by the Writer itself, like wrapped base64 content.
+type lineIndenter interface {
+	appendLineIndent(b []byte) []byte
 }
 
 // NewStandardIndenter creates a StandardIndenter.
@@ -49,15 +106,172 @@ func NewStandardIndenter() *StandardIndenter {
 	return si
 }
 
+func (s *StandardIndenter) appendLineIndent(b []byte) []byte {
+	if s.stack[s.depth].preserve {
+		return b
+	}
+	for d := 0; d < s.depth; d++ {
+		b = append(b, s.IndentString...)
+	}
+	return b
+}
+
+// Reset discards the indenter's state so that it can be used for a new
+// document. It is called by Writer.Reset.
+func (s *StandardIndenter) Reset() {
+	s.depth = 0
+	s.stack = append(s.stack[:0], indentLevel{})
+	s.attrIndex = 0
+	s.attrBreak = false
+	s.preserve, s.preserveOpen = false, false
+	s.suppress = false
+}
+
+// clone returns a copy of the indenter with its own stack, so that the copy
+// and the original can be used on separate goroutines.
+func (s *StandardIndenter) clone() *StandardIndenter {
+	c := *s
+	c.stack = append(make([]indentLevel, 0, len(s.stack)+initialNodeDepth), s.stack...)
+	return &c
+}
+
+// forkIndenter returns a copy of ind for a Writer returned by Writer.Child,
+// or nil if ind isn't one of this package's indenters. A type that embeds a
+// StandardIndenter can't be copied this way without losing its own behaviour.
+func forkIndenter(ind Indenter) Indenter {
+	switch s := ind.(type) {
+	case *StandardIndenter:
+		return s.clone()
+	case *InlineIndenter:
+		return &InlineIndenter{StandardIndenter: s.StandardIndenter.clone(), InlineElems: s.InlineElems}
+	case *SafeIndenter:
+		return &SafeIndenter{StandardIndenter: s.StandardIndenter.clone(), elems: s.elems}
+	case *WrappingIndenter:
+		return &WrappingIndenter{StandardIndenter: s.StandardIndenter.clone(), Width: s.Width}
+	}
+	return nil
+}
+
 // Wrap satisfies the Indenter interface.
 func (s *StandardIndenter) Wrap(content string) string {
 	return content
 }
 
+func (s *StandardIndenter) bufferAttrs() bool {
+	return s.AttrBreak > 0 || s.AttrWidth > 0
+}
+
+func (s *StandardIndenter) indentAttr(w *Writer) error {
+	idx := s.attrIndex
+	s.attrIndex++
+
+	attrs := w.attrs
+	if idx >= len(attrs) {
+		// Not buffered, i.e. written after the element was opened.
+		return nil
+	}
+
+	// The element has already been opened by the time its buffered
+	// attributes are written, so it is one level shallower than the
+	// indenter's depth.
+	depth := s.depth - 1
+	indentLen := depth * utf8.RuneCountInString(s.IndentString)
+	name := ""
+	if w.current >= 0 {
+		name = w.nodes[w.current].elem.fullName()
+	}
+
+	if idx == 0 {
+		s.attrBreak = s.AttrBreak > 0 && len(attrs) > s.AttrBreak
+		if !s.attrBreak && s.AttrWidth > 0 {
+			// '<' + name + attrs + '>'
+			width := indentLen + 1 + utf8.RuneCountInString(name) + 1
+			for _, a := range attrs {
+				// ' ' + name + '="' + value + '"'
+				width += 4 + len(a.Name) + utf8.RuneCountInString(a.Value)
+				if a.Prefix != "" {
+					width += len(a.Prefix) + 1
+				}
+			}
+			s.attrBreak = width > s.AttrWidth
+		}
+	}
+	if !s.attrBreak {
+		return nil
+	}
+
+	if s.AttrAlign {
+		if idx == 0 {
+			return nil
+		}
+		w.printer.WriteString(w.NewlineString)
+		for i := indentLen + utf8.RuneCountInString(name) + 2; i > 0; i-- {
+			w.printer.WriteByte(' ')
+		}
+	} else {
+		w.printer.WriteString(w.NewlineString)
+		for i := 0; i <= depth; i++ {
+			w.printer.WriteString(s.IndentString)
+		}
+	}
+	w.attrIndented = true
+	return w.printer.cachedWriteError()
+}
+
+// matchName reports whether the qualified name made of prefix and name is in
+// names, without allocating it.
+func matchName(names []string, prefix, name string) bool {
+	for _, p := range names {
+		if prefix == "" {
+			if p == name {
+				return true
+			}
+		} else if len(p) == len(prefix)+1+len(name) && p[len(prefix)] == ':' &&
+			p[:len(prefix)] == prefix && p[len(prefix)+1:] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// xmlSpace handles an xml:space attribute. Buffered attributes are written
+// after the element is opened, by which time the element has its own level.
+func (s *StandardIndenter) xmlSpace(value string) {
+	if value != "preserve" && value != "default" {
+		return
+	}
+	preserve := value == "preserve"
+	if s.preserveOpen {
+		s.preserve = preserve
+	} else {
+		s.stack[s.depth].preserve = preserve
+	}
+}
+
 // Indent satisfies the Indenter interface.
 func (s *StandardIndenter) Indent(w *Writer, last Event, next Event) error {
 	// fmt.Print(next.String())
 
+	suppress := s.suppress
+	s.suppress = false
+
+	if next.Node == AttrNode {
+		if next.State != StateOpen {
+			return nil
+		}
+		if (next.Prefix == "xml" && next.Name == "space") || (next.Prefix == "" && next.Name == "xml:space") {
+			s.xmlSpace(next.Value)
+		}
+		if s.bufferAttrs() {
+			return s.indentAttr(w)
+		}
+		return nil
+	} else if next.Node == ElemNode && next.State == StateOpen {
+		s.attrIndex = 0
+		s.preserve = s.stack[s.depth].preserve || matchName(s.PreserveElems, next.Prefix, next.Name)
+		s.preserveOpen = true
+	}
+
 	isIndenting := (next.Node == ElemNode || next.Node == DTDNode ||
 		next.Node == DTDAttListNode)
 
@@ -75,10 +289,16 @@ func (s *StandardIndenter) Indent(w *Writer, last Event, next Event) error {
 
 	isInline := false
 	stackDepth := s.depth
+	preserve := s.stack[stackDepth].preserve || suppress
 
 	if isIndenting {
 		if next.State == StateOpened {
-			s.stack = append(s.stack, indentLevel{e: last})
+			preserve := s.stack[s.depth].preserve
+			if next.Node == ElemNode {
+				preserve = s.preserve
+				s.preserveOpen = false
+			}
+			s.stack = append(s.stack, indentLevel{e: last, preserve: preserve})
 			s.depth++
 		} else if next.State == StateEnded {
 			isInline = s.stack[s.depth].indents == 0
@@ -101,7 +321,11 @@ func (s *StandardIndenter) Indent(w *Writer, last Event, next Event) error {
 		(isIndented && lastIsIndented)) &&
 		last.Node != DocNode
 
-	if pairIsIndented && isIndentedState {
+	if preserve {
+		// Whitespace is significant inside this element, or around this
+		// node.
+
+	} else if pairIsIndented && isIndentedState {
 		isEmptyElem := (next.Node == ElemNode && next.State == StateEnded && next.Children == 0)
 		isInlineCloser := (next.State == StateEnded && isInline)
 
@@ -122,3 +346,267 @@ func (s *StandardIndenter) Indent(w *Writer, last Event, next Event) error {
 
 	return w.printer.cachedWriteError()
 }
+
+// InlineIndenter is a StandardIndenter for mixed content, which keeps a set
+// of elements inline with the surrounding text rather than breaking lines
+// around them, like <b> and <i> in HTML:
+//	w := xmlwriter.Open(b)
+//	w.Indenter = xmlwriter.NewInlineIndenter("b", "i")
+//
+// No whitespace is written before or after an inline element, or anywhere
+// inside it.
+type InlineIndenter struct {
+	*StandardIndenter
+
+	// Qualified names of the inline elements, like "b" or "h:i".
+	InlineElems []string
+}
+
+// NewInlineIndenter creates an InlineIndenter which keeps the named
+// elements inline.
+func NewInlineIndenter(inline ...string) *InlineIndenter {
+	return &InlineIndenter{
+		StandardIndenter: NewStandardIndenter(),
+		InlineElems:      inline,
+	}
+}
+
+// Indent satisfies the Indenter interface.
+func (s *InlineIndenter) Indent(w *Writer, last Event, next Event) error {
+	if next.Node == AttrNode {
+		return s.StandardIndenter.Indent(w, last, next)
+	}
+	inline := next.Node == ElemNode && matchName(s.InlineElems, next.Prefix, next.Name)
+	s.suppress = inline || (last.Node == ElemNode && last.State == StateEnded &&
+		matchName(s.InlineElems, last.Prefix, last.Name))
+
+	err := s.StandardIndenter.Indent(w, last, next)
+	if inline && next.State == StateOpen {
+		// The content of an inline element is treated as though it had
+		// xml:space="preserve", unless it says otherwise.
+		s.preserve = true
+	}
+	return err
+}
+
+// SafeIndenter is a StandardIndenter which never writes whitespace into the
+// content of an element, so that the indented document has the same infoset
+// as the same document written without an Indenter:
+//	w := xmlwriter.Open(b)
+//	w.Indenter = xmlwriter.NewSafeIndenter()
+//
+// Instead of writing whitespace between the nodes inside an element, the
+// line break and indentation go inside the tag before them, just before its
+// closing '>' or '/>':
+//	<a
+//	 ><b
+//	  ><c
+//	  /></b
+//	 ></a>
+//
+// Once Text, CData or Raw has been written inside an element, the element is
+// considered to have mixed content, and no more line breaks are written
+// anywhere inside it.
+type SafeIndenter struct {
+	*StandardIndenter
+
+	// number of elements opened and not yet ended
+	elems int
+}
+
+// tagIndenter is implemented by indenters which write whitespace inside
+// tags rather than between nodes. The Writer calls indentTag just before it
+// writes the '>' which closes a start or end tag, or the '/>' which closes
+// an empty-element tag. The Opened event has already been raised when a
+// start tag or an empty-element tag is closed, and the Ended event when an
+// end tag is closed.
+type tagIndenter interface {
+	indentTag(w *Writer, empty bool) error
+}
+
+// NewSafeIndenter creates a SafeIndenter.
+func NewSafeIndenter() *SafeIndenter {
+	return &SafeIndenter{StandardIndenter: NewStandardIndenter()}
+}
+
+// Reset discards the indenter's state so that it can be used for a new
+// document. It is called by Writer.Reset.
+func (s *SafeIndenter) Reset() {
+	s.StandardIndenter.Reset()
+	s.elems = 0
+}
+
+// Indent satisfies the Indenter interface.
+func (s *SafeIndenter) Indent(w *Writer, last Event, next Event) error {
+	if next.State == StateOpen && (next.Node == TextNode || next.Node == CDataNode || next.Node == RawNode) {
+		// The parent has been opened by the time its content is written,
+		// so it's at the top of the stack.
+		s.stack[s.depth].preserve = true
+	}
+
+	// Whitespace outside the root element can't change its content, so
+	// the StandardIndenter only writes it there.
+	s.suppress = s.elems > 0
+	if next.Node == ElemNode {
+		if next.State == StateOpened {
+			s.elems++
+		} else if next.State == StateEnded {
+			s.elems--
+		}
+	}
+	return s.StandardIndenter.Indent(w, last, next)
+}
+
+func (s *SafeIndenter) indentTag(w *Writer, empty bool) error {
+	// The next node is written at the depth of the element's content after
+	// a start tag, or alongside the element after it has ended. An
+	// empty-element tag is closed before its Ended event, so its own level
+	// is still on the stack.
+	depth := s.depth
+	if empty {
+		depth--
+	}
+	if depth <= 0 || s.stack[depth].preserve {
+		return nil
+	}
+	w.printer.WriteString(w.NewlineString)
+	for i := 0; i < depth; i++ {
+		w.printer.WriteString(s.IndentString)
+	}
+	return w.printer.cachedWriteError()
+}
+
+// WrappingIndenter is a StandardIndenter which also reflows Text and
+// CommentContent so that lines fit within Width columns where possible.
+//
+// Runs of whitespace are collapsed and lines are only ever broken where the
+// content already contains whitespace, so words (and the entities escaped
+// inside them) are never split. Continuation lines are indented to the
+// current depth. Words that are longer than the remaining width are left
+// on a line of their own.
+//
+// Content inside an element with xml:space="preserve" is left alone.
+//
+// WrappingIndenter is used by the WithWrap writer option:
+//	w := xmlwriter.Open(b, xmlwriter.WithWrap(80))
+//
+type WrappingIndenter struct {
+	*StandardIndenter
+
+	// Lines are wrapped to fit within Width characters. If zero, nothing
+	// is wrapped.
+	Width int
+
+	// Wrap doesn't receive the Writer, so it's kept from the last call to
+	// Indent, which always immediately precedes a call to Wrap.
+	w    *Writer
+	next Event
+}
+
+// NewWrappingIndenter creates a WrappingIndenter which wraps text to width.
+func NewWrappingIndenter(width int) *WrappingIndenter {
+	return &WrappingIndenter{
+		StandardIndenter: NewStandardIndenter(),
+		Width:            width,
+	}
+}
+
+// Indent satisfies the Indenter interface.
+func (s *WrappingIndenter) Indent(w *Writer, last Event, next Event) error {
+	s.w, s.next = w, next
+	return s.StandardIndenter.Indent(w, last, next)
+}
+
+// Wrap satisfies the Indenter interface.
+func (s *WrappingIndenter) Wrap(content string) string {
+	w := s.w
+	if s.Width <= 0 || w == nil || s.stack[s.depth].preserve || s.next.State != StateOpen {
+		return content
+	}
+	escaped := s.next.Node == TextNode
+	if !escaped && s.next.Node != CommentContentNode {
+		return content
+	}
+
+	start := 0
+	for start < len(content) && isSpace(content[start]) {
+		start++
+	}
+	if start == len(content) {
+		return content
+	}
+	end := len(content)
+	for isSpace(content[end-1]) {
+		end--
+	}
+
+	indentLen := s.depth * utf8.RuneCountInString(s.IndentString)
+	col := w.printer.Col()
+
+	var out []byte
+	if start > 0 {
+		out = append(out, ' ')
+		col++
+	}
+
+	first := true
+	for i := start; i < end; {
+		j := i
+		for j < end && !isSpace(content[j]) {
+			j++
+		}
+		word := content[i:j]
+		wordLen := wrapLen(word, escaped)
+
+		if !first {
+			if col+1+wordLen > s.Width && col > indentLen {
+				out = append(out, w.NewlineString...)
+				for d := 0; d < s.depth; d++ {
+					out = append(out, s.IndentString...)
+				}
+				col = indentLen
+			} else {
+				out = append(out, ' ')
+				col++
+			}
+		}
+		out = append(out, word...)
+		col += wordLen
+		first = false
+
+		for j < end && isSpace(content[j]) {
+			j++
+		}
+		i = j
+	}
+
+	if end < len(content) {
+		out = append(out, ' ')
+	}
+	return string(out)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// wrapLen returns the number of characters s will occupy once it has been
+// written, including any escaping.
+func wrapLen(s string, escaped bool) int {
+	n := 0
+	for _, r := range s {
+		if escaped {
+			switch r {
+			case '"', '\'', '&':
+				// &#34; &#39; &amp;
+				n += 5
+				continue
+			case '<', '>':
+				n += 4
+				continue
+			}
+		}
+		n++
+	}
+	return n
+}
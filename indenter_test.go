@@ -1,6 +1,8 @@
 package xmlwriter
 
 import (
+	"encoding/xml"
+	"io"
 	"strings"
 	"testing"
 
@@ -172,3 +174,255 @@ func TestIndentRaw(t *testing.T) {
 	)
 	tt.Equals(t, result, str(b, w))
 }
+
+func TestIndentAttrBreak(t *testing.T) {
+	result := strings.Join([]string{
+		"<a>",
+		"  <b",
+		"    one=\"1\"",
+		"    two=\"2\"",
+		"    three=\"3\">",
+		"    <c one=\"1\" two=\"2\"/>",
+		"  </b>",
+		"</a>",
+	}, "\n")
+	b, w := open(WithIndentString("  "))
+	w.Indenter.(*StandardIndenter).AttrBreak = 2
+	must(w.Start(Elem{Name: "a"}))
+	must(w.Start(Elem{Name: "b", Attrs: []Attr{{Name: "one", Value: "1"}, {Name: "two", Value: "2"}}}))
+	must(w.WriteAttr(Attr{Name: "three", Value: "3"}))
+	must(w.Write(Elem{Name: "c", Attrs: []Attr{{Name: "one", Value: "1"}, {Name: "two", Value: "2"}}}))
+	must(w.EndAll())
+	tt.Equals(t, result, str(b, w))
+}
+
+func TestIndentAttrWidthAlign(t *testing.T) {
+	result := strings.Join([]string{
+		"<a>",
+		" <b one=\"1\"",
+		"    p:two=\"2\"",
+		"    xmlns:p=\"urn:p\"/>",
+		" <b one=\"1\"/>",
+		"</a>",
+	}, "\n")
+	b, w := open(WithIndent())
+	si := w.Indenter.(*StandardIndenter)
+	si.AttrWidth = 20
+	si.AttrAlign = true
+	must(w.Start(Elem{Name: "a"}))
+	must(w.Write(Elem{Name: "b", Attrs: []Attr{
+		{Name: "one", Value: "1"},
+		{Prefix: "p", URI: "urn:p", Name: "two", Value: "2"},
+	}}))
+	must(w.Write(Elem{Name: "b", Attrs: []Attr{{Name: "one", Value: "1"}}}))
+	must(w.EndAll())
+	tt.Equals(t, result, str(b, w))
+}
+
+func TestWrapText(t *testing.T) {
+	result := strings.Join([]string{
+		"<a>",
+		" <bb>The quick brown",
+		"  fox jumps over the",
+		"  lazy dog",
+		"  &amp;&amp; cat</bb>",
+		"</a>",
+	}, "\n")
+	b, w := open(WithWrap(20))
+	must(w.Start(Elem{Name: "a"}, Elem{Name: "bb"}))
+	must(w.Write(Text("The quick  brown fox\njumps over the lazy dog && cat")))
+	must(w.EndAll())
+	tt.Equals(t, result, str(b, w))
+}
+
+func TestWrapQuotes(t *testing.T) {
+	// the first line fits exactly once the quotes have been escaped:
+	result := "<p>&#34;a&#34; &#39;b&#39;\n c</p>"
+	b, w := open(WithWrap(26))
+	must(w.Write(Elem{Name: "p", Content: []Writable{Text(`"a" 'b' c`)}}))
+	must(w.EndAll())
+	tt.Equals(t, result, str(b, w))
+	tt.Equals(t, 26, strings.Index(result, "\n"))
+}
+
+func TestWrapComment(t *testing.T) {
+	result := strings.Join([]string{
+		"<a>",
+		" <!--one two",
+		" three four-->",
+		"</a>",
+	}, "\n")
+	b, w := open(WithWrap(12))
+	must(w.Start(Elem{Name: "a"}))
+	must(w.Write(Comment{Content: "one two three four"}))
+	must(w.EndAll())
+	tt.Equals(t, result, str(b, w))
+}
+
+func TestWrapPreserve(t *testing.T) {
+	result := strings.Join([]string{
+		"<a xml:space=\"preserve\"><b>one  two three four</b><c xml:space=\"default\">one",
+		"  two three",
+		"  four</c></a>",
+	}, "\n")
+	b, w := open(WithWrap(12))
+	must(w.Start(Elem{Name: "a", Attrs: []Attr{{Prefix: "xml", Name: "space", Value: "preserve"}}}))
+	must(w.Write(Elem{Name: "b", Content: []Writable{Text("one  two three four")}}))
+	must(w.Write(Elem{
+		Name:    "c",
+		Attrs:   []Attr{{Prefix: "xml", Name: "space", Value: "default"}},
+		Content: []Writable{Text("one two three four")},
+	}))
+	must(w.EndAll())
+	tt.Equals(t, result, str(b, w))
+}
+
+func TestIndentPreserve(t *testing.T) {
+	result := strings.Join([]string{
+		"<a>",
+		" <b xml:space=\"preserve\"><c><d/></c><c xml:space=\"default\">",
+		"   <d/>",
+		"  </c></b>",
+		" <pre><c/></pre>",
+		" <c>",
+		"  <d/>",
+		" </c>",
+		"</a>",
+	}, "\n")
+	// the prefix can also be written as part of the name:
+	space := []Attr{{Prefix: "xml", Name: "space"}, {Name: "xml:space"}}
+
+	for _, order := range []AttrOrder{AttrOrderNone, AttrOrderName} {
+		for _, attr := range space {
+			b, w := open(WithIndent(), WithAttrOrder(order))
+			w.Indenter.(*StandardIndenter).PreserveElems = []string{"pre"}
+			must(w.Start(Elem{Name: "a"}))
+			must(w.Start(Elem{Name: "b"}))
+			preserve, dflt := attr, attr
+			preserve.Value, dflt.Value = "preserve", "default"
+			must(w.Write(preserve))
+			must(w.Write(Elem{Name: "c", Content: []Writable{Elem{Name: "d"}}}))
+			must(w.Start(Elem{Name: "c", Attrs: []Attr{dflt}}))
+			must(w.Write(Elem{Name: "d"}))
+			must(w.End(ElemNode))
+			must(w.End(ElemNode))
+			must(w.Write(Elem{Name: "pre", Content: []Writable{Elem{Name: "c"}}}))
+			must(w.Write(Elem{Name: "c", Content: []Writable{Elem{Name: "d"}}}))
+			must(w.EndAll())
+			tt.Equals(t, result, str(b, w))
+		}
+	}
+}
+
+func TestIndentInline(t *testing.T) {
+	result := strings.Join([]string{
+		"<body>",
+		" <p><b>Bold <i>and</i></b> text, <i>italic</i>.</p>",
+		" <p>",
+		"  <span/>",
+		" </p>",
+		"</body>",
+	}, "\n")
+	b, w := open()
+	w.Indenter = NewInlineIndenter("b", "i")
+	ec := &ErrCollector{}
+	ec.Must(
+		w.Start(Elem{Name: "body"}, Elem{Name: "p"}, Elem{Name: "b"}),
+		w.Write(Text("Bold "), Elem{Name: "i", Content: []Writable{Text("and")}}),
+		w.End(ElemNode),
+		w.Write(Text(" text, "), Elem{Name: "i", Content: []Writable{Text("italic")}}, Text(".")),
+		w.End(ElemNode),
+		w.Write(Elem{Name: "p", Content: []Writable{Elem{Name: "span"}}}),
+		w.EndAll(),
+	)
+	tt.Equals(t, result, str(b, w))
+}
+
+type eventRecorder struct {
+	*StandardIndenter
+	events []Event
+}
+
+func (r *eventRecorder) Indent(w *Writer, last Event, next Event) error {
+	r.events = append(r.events, next)
+	return r.StandardIndenter.Indent(w, last, next)
+}
+
+func TestIndentEventPayload(t *testing.T) {
+	_, w := open()
+	rec := &eventRecorder{StandardIndenter: NewStandardIndenter()}
+	w.Indenter = rec
+	ec := &ErrCollector{}
+	ec.Must(
+		w.Start(Doc{}),
+		w.Start(Elem{Prefix: "p", URI: "urn:p", Name: "root", Attrs: []Attr{{Name: "a", Value: "1"}}}),
+		w.Write(Attr{Prefix: "p", Name: "b", Value: "2"}),
+		w.Write(Text("hi")),
+		w.EndAll(),
+	)
+	tt.Equals(t, []Event{
+		{State: StateOpen, Node: DocNode},
+		{State: StateOpened, Node: DocNode, Children: 1},
+		{State: StateOpen, Node: ElemNode, Prefix: "p", Name: "root", URI: "urn:p", Depth: 1, Attrs: 1},
+		{State: StateOpen, Node: AttrNode, Name: "a", Value: "1", Depth: 2},
+		{State: StateOpen, Node: AttrNode, Prefix: "p", Name: "b", Value: "2", URI: "urn:p", Depth: 2},
+		{State: StateOpened, Node: ElemNode, Children: 1, Prefix: "p", Name: "root", URI: "urn:p", Depth: 1, Attrs: 2},
+		{State: StateOpen, Node: TextNode, Depth: 2},
+		{State: StateEnded, Node: ElemNode, Children: 1, Prefix: "p", Name: "root", URI: "urn:p", Depth: 1, Attrs: 2},
+		{State: StateEnded, Node: DocNode, Children: 1},
+	}, rec.events)
+}
+
+func TestIndentSafe(t *testing.T) {
+	result := strings.Join([]string{
+		"<a",
+		" ><p",
+		"  >text<b/><c><d/></c><!--x--></p",
+		" ><p",
+		"  ><b",
+		"  />text<c/></p",
+		" ><e",
+		"  ><f",
+		"  /></e",
+		" ></a>",
+	}, "\n")
+
+	write := func(w *Writer) {
+		ec := &ErrCollector{}
+		ec.Must(
+			w.Start(Elem{Name: "a"}, Elem{Name: "p"}),
+			w.Write(Text("text"), Elem{Name: "b"}, Elem{Name: "c", Content: []Writable{Elem{Name: "d"}}}),
+			w.Write(Comment{Content: "x"}),
+			w.End(ElemNode),
+			w.Write(Elem{Name: "p", Content: []Writable{Elem{Name: "b"}, Text("text"), Elem{Name: "c"}}}),
+			w.Write(Elem{Name: "e", Content: []Writable{Elem{Name: "f"}}}),
+			w.EndAll(),
+		)
+	}
+
+	b, w := open()
+	w.Indenter = NewSafeIndenter()
+	write(w)
+	indented := str(b, w)
+	tt.Equals(t, result, indented)
+
+	b, w = open()
+	write(w)
+	tt.Equals(t, infoset(t, str(b, w)), infoset(t, indented))
+}
+
+// infoset returns the tokens encoding/xml reads from doc, which don't include
+// any whitespace inside tags.
+func infoset(t *testing.T, doc string) []xml.Token {
+	t.Helper()
+	var toks []xml.Token
+	dec := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return toks
+		}
+		tt.OK(t, err)
+		toks = append(toks, xml.CopyToken(tok))
+	}
+}
@@ -0,0 +1,54 @@
+package xmlwriter
+
+import "fmt"
+
+// Limits protects against runaway output, for example from user-driven
+// exports. A zero value for any of the fields means there is no limit.
+// Limits are set using the WithLimits writer option:
+//
+//	w := xmlwriter.Open(b, xmlwriter.WithLimits(xmlwriter.Limits{MaxDepth: 100}))
+//
+// Writing a node which would exceed a limit fails with a *LimitError.
+type Limits struct {
+	// Maximum number of nodes that can be open at once, including the Doc.
+	MaxDepth int
+
+	// Maximum number of bytes of output, before encoding. Once this has been
+	// exceeded, the Writer's output is left unfinished at the last write
+	// which fit, and every subsequent write fails.
+	MaxBytes int64
+
+	// Maximum number of attributes on an element. Namespace declarations
+	// made by Elem.URI and Attr.URI are not counted.
+	MaxAttrs int
+
+	// Maximum length in bytes of a single Text, CDataContent or
+	// CommentContent, including the Content of a CData or Comment.
+	MaxTextLen int
+}
+
+// WithLimits sets the Writer's Limits.
+func WithLimits(limits Limits) Option {
+	return func(w *Writer) {
+		w.limits = limits
+	}
+}
+
+// LimitError is returned when a node would exceed one of the Writer's
+// Limits.
+type LimitError struct {
+	// Name of the Limits field that was exceeded, e.g. "MaxDepth".
+	Limit string
+	Max   int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("xmlwriter: %s limit of %d exceeded", e.Limit, e.Max)
+}
+
+func (w *Writer) checkTextLen(n int) error {
+	if w.limits.MaxTextLen > 0 && n > w.limits.MaxTextLen {
+		return &LimitError{Limit: "MaxTextLen", Max: int64(w.limits.MaxTextLen)}
+	}
+	return nil
+}
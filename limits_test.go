@@ -0,0 +1,66 @@
+package xmlwriter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+)
+
+func assertLimit(t *testing.T, limit string, err error) {
+	t.Helper()
+	var lerr *LimitError
+	tt.Assert(t, errors.As(err, &lerr), "expected *LimitError, found %v", err)
+	tt.Equals(t, limit, lerr.Limit)
+}
+
+func TestLimitDepth(t *testing.T) {
+	b, w := open(WithLimits(Limits{MaxDepth: 3}))
+	tt.OK(t, w.Start(Doc{}, Elem{Name: "a"}, Elem{Name: "b"}))
+	assertLimit(t, "MaxDepth", w.Start(Elem{Name: "c"}))
+	assertLimit(t, "MaxDepth", w.Write(Comment{Content: "c"}))
+	tt.OK(t, w.Write(Text("ok")))
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<a><b>ok</b></a>", str(b, w))
+}
+
+func TestLimitBytes(t *testing.T) {
+	b, w := open(WithLimits(Limits{MaxBytes: 9}))
+	tt.OK(t, w.Start(Elem{Name: "a"}))
+	tt.OK(t, w.Write(Text("1234")))
+	assertLimit(t, "MaxBytes", w.Write(Text("5678")))
+	assertLimit(t, "MaxBytes", w.Write(Text("")))
+	assertLimit(t, "MaxBytes", w.Flush())
+	tt.Equals(t, "<a>1234", b.String())
+}
+
+func TestLimitBytesLargeWrite(t *testing.T) {
+	b, w := open(WithLimits(Limits{MaxBytes: 5000}))
+	long := strings.Repeat("x", 4000)
+	tt.OK(t, w.Write(Raw(long)))
+	assertLimit(t, "MaxBytes", w.Write(Raw(long)))
+	assertLimit(t, "MaxBytes", w.Flush())
+	tt.Equals(t, 4000, b.Len())
+}
+
+func TestLimitAttrs(t *testing.T) {
+	_, w := open(WithLimits(Limits{MaxAttrs: 2}))
+	err := w.Start(Elem{Name: "a", Attrs: []Attr{{Name: "a"}, {Name: "b"}, {Name: "c"}}})
+	assertLimit(t, "MaxAttrs", err)
+
+	_, w = open(WithLimits(Limits{MaxAttrs: 2}))
+	tt.OK(t, w.Start(Elem{Name: "a", Prefix: "p", URI: "urn:p", Attrs: []Attr{{Name: "a"}}}))
+	tt.OK(t, w.WriteAttr(Attr{Name: "b"}))
+	assertLimit(t, "MaxAttrs", w.WriteAttr(Attr{Name: "c"}))
+	tt.OK(t, w.Write(Elem{Name: "b", Attrs: []Attr{{Name: "a"}, {Name: "b"}}}))
+}
+
+func TestLimitTextLen(t *testing.T) {
+	for _, n := range []Writable{Text("abcd"), CData{Content: "abcd"}, Comment{Content: "abcd"}} {
+		_, w := open(WithLimits(Limits{MaxTextLen: 3}))
+		tt.OK(t, w.Start(Elem{Name: "a"}))
+		tt.OK(t, w.Write(Text("abc")))
+		assertLimit(t, "MaxTextLen", w.Write(n))
+	}
+}
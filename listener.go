@@ -0,0 +1,65 @@
+package xmlwriter
+
+// Listener receives every Event raised by a Writer, for things like progress
+// reporting, metrics, auditing or custom validation. If a Listener returns an
+// error, the Writer method that raised the event returns it.
+//
+// Listeners are called after the Indenter for the same Event. StateOpen and
+// StateOpened events are raised before the node's output for that state is
+// written. StateEnded events are raised after the node has been written,
+// with Event.Bytes set to the number of bytes it took.
+//
+// Listeners are added with the WithListener writer option, or with
+// Writer.AddListener:
+//	w := xmlwriter.Open(b, xmlwriter.WithListener(xmlwriter.ListenerFunc(
+//		func(w *xmlwriter.Writer, ev xmlwriter.Event) error {
+//			fmt.Println(ev)
+//			return nil
+//		})))
+//
+type Listener interface {
+	Event(w *Writer, ev Event) error
+}
+
+// ListenerFunc adapts a function to the Listener interface.
+type ListenerFunc func(w *Writer, ev Event) error
+
+// Event satisfies the Listener interface.
+func (f ListenerFunc) Event(w *Writer, ev Event) error {
+	return f(w, ev)
+}
+
+// WithListener adds a Listener to the Writer.
+func WithListener(l Listener) Option {
+	return func(w *Writer) {
+		w.AddListener(l)
+	}
+}
+
+// AddListener adds a Listener which will receive every subsequent Event
+// raised by the Writer.
+func (w *Writer) AddListener(l Listener) {
+	w.listeners = append(w.listeners, l)
+}
+
+func (w *Writer) notify(ev Event) error {
+	for _, l := range w.listeners {
+		if err := l.Event(w, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// endLeaf is called once a node that can't be started has been written.
+func (w *Writer) endLeaf(kind NodeKind) error {
+	if w.Indenter != nil {
+		w.last = w.leafEvent(StateEnded, kind)
+	}
+	if len(w.listeners) > 0 {
+		ev := w.leafEvent(StateEnded, kind)
+		ev.Bytes = int(w.printer.Offset() - w.leafStart)
+		return w.notify(ev)
+	}
+	return nil
+}
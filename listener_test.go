@@ -0,0 +1,80 @@
+package xmlwriter
+
+import (
+	"fmt"
+	"testing"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+)
+
+type eventLog []string
+
+func (l *eventLog) Event(w *Writer, ev Event) error {
+	*l = append(*l, fmt.Sprintf("%s %s %s %d", ev.State.Name(), ev.Node.Name(), ev.Name, ev.Bytes))
+	return nil
+}
+
+func TestListener(t *testing.T) {
+	var log eventLog
+	b, w := open(WithListener(&log))
+	ec := &ErrCollector{}
+	ec.Must(
+		w.Start(Elem{Name: "foo", Attrs: []Attr{{Name: "a", Value: "1"}}}),
+		w.Write(Text("a&b"), Elem{Name: "bar"}),
+		w.EndAll(),
+	)
+	tt.Equals(t, `<foo a="1">a&amp;b<bar/></foo>`, str(b, w))
+	tt.Equals(t, eventLog{
+		"open elem foo 0",
+		"open attr a 0",
+		"ended attr a 6",
+		"opened elem foo 0",
+		"open text  0",
+		"ended text  7",
+		"open elem bar 0",
+		"opened elem bar 0",
+		"ended elem bar 6",
+		"ended elem foo 30",
+	}, log)
+}
+
+func TestListenerIndent(t *testing.T) {
+	var log eventLog
+	b, w := open(WithIndent(), WithListener(&log))
+	ec := &ErrCollector{}
+	ec.Must(w.Start(Elem{Name: "foo"}), w.Write(Elem{Name: "bar"}), w.EndAll())
+	tt.Equals(t, "<foo>\n <bar/>\n</foo>", str(b, w))
+
+	// The indent before <bar/> belongs to <foo>, not <bar/>:
+	tt.Equals(t, "ended elem bar 6", log[len(log)-2])
+	tt.Equals(t, "ended elem foo 20", log[len(log)-1])
+}
+
+func TestListenerError(t *testing.T) {
+	b, w := open()
+	w.AddListener(ListenerFunc(func(w *Writer, ev Event) error {
+		if ev.Node == ElemNode && ev.Name == "bad" && ev.State == StateOpen {
+			return fmt.Errorf("no bad elements")
+		}
+		return nil
+	}))
+	tt.OK(t, w.Start(Elem{Name: "foo"}))
+	tt.Pattern(t, `no bad elements`, w.Start(Elem{Name: "bad"}).Error())
+	tt.Pattern(t, `no bad elements`, w.Flush().Error())
+	tt.Equals(t, "<foo>", b.String())
+}
+
+func TestListenerErrorOpened(t *testing.T) {
+	b, w := open()
+	w.AddListener(ListenerFunc(func(w *Writer, ev Event) error {
+		if ev.Node == ElemNode && ev.State == StateOpened {
+			return fmt.Errorf("not opened")
+		}
+		return nil
+	}))
+	tt.OK(t, w.Start(Elem{Name: "a"}))
+	tt.Pattern(t, `not opened`, w.WriteText("hello").Error())
+	tt.Pattern(t, `not opened`, w.Err().Error())
+	tt.Pattern(t, `not opened`, w.Flush().Error())
+	tt.Equals(t, "<a", b.String())
+}
@@ -2,15 +2,48 @@ package xmlwriter
 
 import "fmt"
 
-// Event is raised when a node changes state in the writer. It is currently
-// only relevant to the Indenter but may become an event system in a later
-// version.
+// Event is raised when a node changes state in the writer. Events are passed
+// to the Indenter and to any Listeners.
 type Event struct {
 	State    NodeState
 	Node     NodeKind
 	Children int
+
+	// Prefix and Name are set for ElemNode and AttrNode events. Value is set
+	// for AttrNode events.
+	Prefix string
+	Name   string
+	Value  string
+
+	// URI is the namespace bound to Prefix by the Elem.URI or Attr.URI of
+	// the node or one of its ancestors, if there is one.
+	URI string
+
+	// Depth is the number of nodes enclosing the node, i.e. a root element
+	// is at depth 0, or at depth 1 if it is inside a Doc. The attributes of
+	// an element are enclosed by it.
+	Depth int
+
+	// Attrs is the number of attributes written to an ElemNode so far,
+	// including any waiting to be written from Elem.Attrs. More attributes
+	// can be written until the element is opened, so the count is only
+	// final from StateOpened. Namespace declarations made by Elem.URI or
+	// Attr.URI are not counted.
+	Attrs int
+
+	// Bytes is set on StateEnded events raised to a Listener. It is the
+	// number of bytes written for the node, including its children, before
+	// any encoding.
+	Bytes int
 }
 
 func (e Event) String() string {
+	if e.Name != "" {
+		name := e.Name
+		if e.Prefix != "" {
+			name = e.Prefix + ":" + name
+		}
+		return fmt.Sprintf("%d\t%s\t+%d\t%s", e.State, e.Node.Name(), e.Children, name)
+	}
 	return fmt.Sprintf("%d\t%s\t+%d", e.State, e.Node.Name(), e.Children)
 }
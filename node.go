@@ -64,6 +64,12 @@ type node struct {
 	// of an element if the element only contains cdata or comments
 	hasIndenter bool
 
+	// number of attributes written to an element
+	attrs int
+
+	// printer offset where the node starts, after any indentation
+	start int64
+
 	// bogus tagged union, this keeps things from escaping to the heap
 	kind       NodeKind
 	flag       nodeFlag
@@ -75,6 +81,21 @@ type node struct {
 	elem       Elem
 }
 
+// event returns the Event raised for the node's current state. The node
+// must be the Writer's current node.
+func (n *node) event(w *Writer) Event {
+	ev := Event{State: n.state, Node: n.kind, Children: n.children, Depth: w.current}
+	if n.kind == ElemNode {
+		ev.Prefix, ev.Name = n.elem.Prefix, n.elem.Name
+		ev.Attrs = n.attrs + len(n.elem.Attrs)
+		ev.URI = n.elem.URI
+		if ev.URI == "" && ev.Prefix != "" {
+			ev.URI = w.lookupNS(ev.Prefix)
+		}
+	}
+	return ev
+}
+
 func (n *node) clear() {
 	*n = node{}
 }
@@ -85,17 +106,24 @@ func (n *node) open(w *Writer) error {
 	n.state = StateOpen
 
 	if w.Indenter != nil {
-		ev := Event{n.state, n.kind, n.children}
-		if err := w.writeIndent(ev); err != nil {
+		if err := w.writeIndent(n.event(w)); err != nil {
+			return err
+		}
+		w.last = n.event(w)
+	}
+	if len(w.listeners) > 0 {
+		n.start = w.printer.Offset()
+		if err := w.notify(n.event(w)); err != nil {
 			return err
 		}
-		w.last = Event{n.state, n.kind, n.children}
 	}
 
 	switch n.kind {
 	case CommentNode:
+		w.stats.Comments++
 		err = n.comment.open(n, w)
 	case CDataNode:
+		w.stats.CDatas++
 		err = n.cdata.open(n, w)
 	case DocNode:
 		err = n.doc.open(n, w)
@@ -104,6 +132,7 @@ func (n *node) open(w *Writer) error {
 	case DTDAttListNode:
 		err = n.dtdAttList.open(n, w)
 	case ElemNode:
+		w.stats.Elems++
 		err = n.elem.open(n, w)
 	default:
 		// FIXME
@@ -119,8 +148,12 @@ func (n *node) opened(w *Writer) error {
 	n.state = StateOpened
 
 	if w.Indenter != nil {
-		ev := Event{n.state, n.kind, n.children}
-		if err := w.writeIndent(ev); err != nil {
+		if err := w.writeIndent(n.event(w)); err != nil {
+			return err
+		}
+	}
+	if len(w.listeners) > 0 {
+		if err := w.notify(n.event(w)); err != nil {
 			return err
 		}
 	}
@@ -143,7 +176,7 @@ func (n *node) opened(w *Writer) error {
 		panic(nil)
 	}
 	if w.Indenter != nil {
-		w.last = Event{n.state, n.kind, n.children}
+		w.last = n.event(w)
 	}
 	return err
 }
@@ -160,8 +193,7 @@ func (n *node) end(w *Writer) error {
 
 	n.state = StateEnded
 	if w.Indenter != nil {
-		ev := Event{n.state, n.kind, n.children}
-		if err := w.writeIndent(ev); err != nil {
+		if err := w.writeIndent(n.event(w)); err != nil {
 			return err
 		}
 	}
@@ -184,7 +216,12 @@ func (n *node) end(w *Writer) error {
 		panic(nil)
 	}
 	if w.Indenter != nil {
-		w.last = Event{n.state, n.kind, n.children}
+		w.last = n.event(w)
+	}
+	if err == nil && len(w.listeners) > 0 {
+		ev := n.event(w)
+		ev.Bytes = int(w.printer.Offset() - n.start)
+		err = w.notify(ev)
 	}
 	return err
 }
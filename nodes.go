@@ -1,6 +1,7 @@
 package xmlwriter
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 )
@@ -18,8 +19,20 @@ func (t Raw) write(w *Writer) error {
 		return err
 	}
 	w.printer.WriteString(string(t))
-	if w.Indenter != nil {
-		w.last = Event{StateEnded, RawNode, 0}
+	if err := w.endLeaf(RawNode); err != nil {
+		return err
+	}
+	return w.printer.cachedWriteError()
+}
+
+// writeRawBytes is Raw.write for a byte slice.
+func (w *Writer) writeRawBytes(b []byte) error {
+	if err := w.writeBeginCur(RawNode); err != nil {
+		return err
+	}
+	w.printer.Write(b)
+	if err := w.endLeaf(RawNode); err != nil {
+		return err
 	}
 	return w.printer.cachedWriteError()
 }
@@ -32,25 +45,72 @@ func (t Text) kind() NodeKind { return TextNode }
 
 func (t Text) write(w *Writer) error {
 	s := string(t)
-	if w.Indenter != nil {
-		s = w.Indenter.Wrap(s)
-	}
 	if w.Enforce {
 		if err := w.checkParent(noNodeFlag | elemNodeFlag); err != nil {
 			return err
 		}
 		// TODO: CharData ::= [^<&]* - ([^<&]* ']]>' [^<&]*)
 	}
+	if err := w.checkTextLen(len(s)); err != nil {
+		return err
+	}
 	if err := w.writeBeginNext(TextNode); err != nil {
 		return err
 	}
-	err := w.printer.EscapeString(s)
+	w.stats.Texts++
 	if w.Indenter != nil {
-		w.last = Event{StateEnded, TextNode, 0}
+		// Wrapped after the parent is opened and the indenter has been told
+		// about the text, so the indenter knows where the text will start.
+		s = w.Indenter.Wrap(s)
+	}
+	err := w.printer.EscapeString(s)
+	if err := w.endLeaf(TextNode); err != nil {
+		return err
+	}
+	return err
+}
+
+// writeTextBytes writes a text node from a byte slice. If escape is false, b
+// must not need escaping or wrapping, like the output of strconv used by
+// WriteTextInt and friends.
+func (w *Writer) writeTextBytes(b []byte, escape bool) error {
+	if err := w.beginText(len(b)); err != nil {
+		return err
+	}
+	var err error
+	switch {
+	case !escape:
+		_, err = w.printer.Write(b)
+	case w.Indenter != nil:
+		// Indenter.Wrap works on strings, so b has to be copied:
+		err = w.printer.EscapeString(w.Indenter.Wrap(string(b)))
+	default:
+		err = w.printer.EscapeBytes(b)
+	}
+	if err := w.endLeaf(TextNode); err != nil {
+		return err
 	}
 	return err
 }
 
+// beginText starts a text node of n bytes, which must be written to the
+// printer and then ended by endLeaf.
+func (w *Writer) beginText(n int) error {
+	if w.Enforce {
+		if err := w.checkParent(noNodeFlag | elemNodeFlag); err != nil {
+			return err
+		}
+	}
+	if err := w.checkTextLen(n); err != nil {
+		return err
+	}
+	if err := w.writeBeginNext(TextNode); err != nil {
+		return err
+	}
+	w.stats.Texts++
+	return nil
+}
+
 // CommentContent represents a text portion of an XML comment which can
 // be written after a Comment is Started.
 type CommentContent string
@@ -59,9 +119,6 @@ func (c CommentContent) kind() NodeKind { return CommentContentNode }
 
 func (c CommentContent) write(w *Writer) error {
 	s := string(c)
-	if w.Indenter != nil {
-		s = w.Indenter.Wrap(s)
-	}
 	if w.Enforce {
 		if err := w.checkParent(noNodeFlag | commentNodeFlag); err != nil {
 			return err
@@ -75,18 +132,56 @@ func (c CommentContent) write(w *Writer) error {
 		}
 	}
 
+	if err := w.checkTextLen(len(s)); err != nil {
+		return err
+	}
 	if err := w.writeBeginNext(CommentContentNode); err != nil {
 		return err
 	}
+	if w.Indenter != nil {
+		s = w.Indenter.Wrap(s)
+	}
 	if _, err := w.printer.WriteString(s); err != nil {
 		return err
 	}
-	if w.Indenter != nil {
-		w.last = Event{StateEnded, CommentContentNode, 0}
+	if err := w.endLeaf(CommentContentNode); err != nil {
+		return err
 	}
 	return nil
 }
 
+// writeCommentContentBytes is CommentContent.write for a byte slice.
+func (w *Writer) writeCommentContentBytes(b []byte) error {
+	if w.Enforce {
+		if err := w.checkParent(noNodeFlag | commentNodeFlag); err != nil {
+			return err
+		}
+		if bytes.Contains(b, []byte("--")) {
+			return fmt.Errorf("xmlwriter: comment may not contain '--'")
+		}
+		if err := checkCharsBytes(b, w.StrictChars); err != nil {
+			return err
+		}
+	}
+
+	if err := w.checkTextLen(len(b)); err != nil {
+		return err
+	}
+	if err := w.writeBeginNext(CommentContentNode); err != nil {
+		return err
+	}
+	var err error
+	if w.Indenter != nil {
+		_, err = w.printer.WriteString(w.Indenter.Wrap(string(b)))
+	} else {
+		_, err = w.printer.Write(b)
+	}
+	if err != nil {
+		return err
+	}
+	return w.endLeaf(CommentContentNode)
+}
+
 // Comment represents an XML comment section which can be written or
 // started by the Writer.
 type Comment struct {
@@ -158,14 +253,17 @@ func (c CDataContent) write(w *Writer) error {
 		}
 	}
 
+	if err := w.checkTextLen(len(s)); err != nil {
+		return err
+	}
 	if err := w.writeBeginNext(CDataContentNode); err != nil {
 		return err
 	}
 	if _, err := w.printer.WriteString(s); err != nil {
 		return err
 	}
-	if w.Indenter != nil {
-		w.last = Event{StateEnded, CDataContentNode, 0}
+	if err := w.endLeaf(CDataContentNode); err != nil {
+		return err
 	}
 	return nil
 }
@@ -188,6 +286,32 @@ func (c CData) start(w *Writer) error {
 	return w.pushEnd()
 }
 
+// writeCDataContentBytes is CDataContent.write for a byte slice.
+func (w *Writer) writeCDataContentBytes(b []byte) error {
+	if w.Enforce {
+		if err := w.checkParent(noNodeFlag | cDataNodeFlag); err != nil {
+			return err
+		}
+		if bytes.Contains(b, []byte("]]>")) {
+			return fmt.Errorf("xmlwriter: cdata may not contain ']]>'")
+		}
+		if err := checkCharsBytes(b, w.StrictChars); err != nil {
+			return err
+		}
+	}
+
+	if err := w.checkTextLen(len(b)); err != nil {
+		return err
+	}
+	if err := w.writeBeginNext(CDataContentNode); err != nil {
+		return err
+	}
+	if _, err := w.printer.Write(b); err != nil {
+		return err
+	}
+	return w.endLeaf(CDataContentNode)
+}
+
 func (c CData) kind() NodeKind { return CDataNode }
 
 func (c CData) write(w *Writer) error {
@@ -365,7 +489,7 @@ func (p PI) write(w *Writer) error {
 		if strings.ToLower(p.Target) == "xml" {
 			return fmt.Errorf("xmlwriter: PI target may not be 'xml'")
 		}
-		if err := CheckName(p.Target); err != nil {
+		if err := w.printer.names.check(p.Target); err != nil {
 			return err
 		}
 		if strings.Index(p.Content, "?>") >= 0 {
@@ -385,8 +509,8 @@ func (p PI) write(w *Writer) error {
 	w.printer.WriteString("?>")
 
 	err := w.printer.cachedWriteError()
-	if w.Indenter != nil {
-		w.last = Event{StateEnded, PINode, 0}
+	if err := w.endLeaf(PINode); err != nil {
+		return err
 	}
 	return err
 }
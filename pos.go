@@ -0,0 +1,99 @@
+package xmlwriter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Pos is a position in the Writer's output.
+type Pos struct {
+	// Line and Col start at 1. Col counts characters rather than bytes, so
+	// it is the same whatever the encoding is.
+	Line int
+	Col  int
+
+	// Offset is the number of bytes before the position, starting at 0.
+	Offset int64
+}
+
+// Pos returns the position that the next byte written by the Writer will
+// appear at.
+//
+// Offsets are only known if the output is UTF-8. If the Writer was opened
+// by OpenEncoding with any other encoding, Pos returns an error along with
+// the Line and Col, and Offset is -1.
+func (w *Writer) Pos() (Pos, error) {
+	line, col := w.printer.pos()
+	pos := Pos{Line: line + 1, Col: col + 1, Offset: w.printer.Offset()}
+	if err := w.checkOffsets(); err != nil {
+		pos.Offset = -1
+		return pos, err
+	}
+	return pos, nil
+}
+
+// checkOffsets returns an error if the printer's offsets aren't offsets into
+// the output. The printer counts bytes before they are encoded, and an
+// encoder can hold bytes back, so offsets can't be counted after it either.
+func (w *Writer) checkOffsets() error {
+	if w.encoded == nil || strings.EqualFold(w.encoding, "utf-8") || strings.EqualFold(w.encoding, "utf8") {
+		return nil
+	}
+	return fmt.Errorf("xmlwriter: byte offsets are not known for encoding %q", w.encoding)
+}
+
+// ElemSpan is the extent of an element in the Writer's output, see
+// WithElemSpans.
+type ElemSpan struct {
+	Prefix string
+	Name   string
+	Depth  int
+
+	// Start is the position of the '<' of the start tag, End is the
+	// position just after the end tag.
+	Start Pos
+	End   Pos
+}
+
+// WithElemSpans calls f with the extent of each element once it has been
+// written, to build a source map or an index of the document. Elements
+// are reported in the order they end, so children come before their
+// parents. Starting an element fails if the Writer's offsets aren't known,
+// see Writer.Pos.
+func WithElemSpans(f func(span ElemSpan)) Option {
+	return WithListener(&spanListener{f: f})
+}
+
+type spanListener struct {
+	f      func(span ElemSpan)
+	starts []Pos
+}
+
+func (s *spanListener) Event(w *Writer, ev Event) error {
+	if ev.Node != ElemNode {
+		return nil
+	}
+	switch ev.State {
+	case StateOpen:
+		pos, err := w.Pos()
+		if err != nil {
+			return err
+		}
+		s.starts = append(s.starts, pos)
+	case StateEnded:
+		end, err := w.Pos()
+		if err != nil {
+			return err
+		}
+		last := len(s.starts) - 1
+		s.f(ElemSpan{
+			Prefix: ev.Prefix,
+			Name:   ev.Name,
+			Depth:  ev.Depth,
+			Start:  s.starts[last],
+			End:    end,
+		})
+		s.starts = s.starts[:last]
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package xmlwriter
+
+import (
+	"bytes"
+	"testing"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func pos(t *testing.T, w *Writer) Pos {
+	t.Helper()
+	p, err := w.Pos()
+	tt.OK(t, err)
+	return p
+}
+
+func TestPos(t *testing.T) {
+	b, w := open(WithIndent())
+	tt.Equals(t, Pos{Line: 1, Col: 1}, pos(t, w))
+
+	tt.OK(t, w.Start(Elem{Name: "a"}, Elem{Name: "b"}))
+	tt.OK(t, w.Write(Text("é")))
+	tt.Equals(t, Pos{Line: 2, Col: 6, Offset: 10}, pos(t, w))
+
+	tt.OK(t, w.Flush())
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, Pos{Line: 3, Col: 5, Offset: 19}, pos(t, w))
+	tt.Equals(t, "<a>\n <b>é</b>\n</a>", str(b, w))
+}
+
+func TestPosNewlines(t *testing.T) {
+	for _, nl := range []string{"\n", "\r\n", "\r"} {
+		_, w := open(WithIndent())
+		w.NewlineString = nl
+		must(w.Start(Elem{Name: "a"}, Elem{Name: "b"}))
+		must(w.Write(Raw("x\r"), Raw("\ny")))
+		tt.Equals(t, 3, pos(t, w).Line)
+		tt.Equals(t, 2, pos(t, w).Col)
+	}
+}
+
+func TestPosSmallBuffer(t *testing.T) {
+	_, w := open(WithIndent(), func(w *Writer) { w.InitialBufSize = 3 })
+	must(w.Start(Elem{Name: "a"}))
+	must(w.Write(Elem{Name: "bbbbbb"}, Text("ééé")))
+	tt.Equals(t, Pos{Line: 2, Col: 14, Offset: 20}, pos(t, w))
+	must(w.Write(Raw("\r"), Raw("\n")))
+	tt.Equals(t, Pos{Line: 3, Col: 1, Offset: 22}, pos(t, w))
+}
+
+func TestPosEncoding(t *testing.T) {
+	enc := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder()
+	w := OpenEncoding(&bytes.Buffer{}, "utf-16be", enc, WithIndent())
+	must(w.Start(Elem{Name: "a"}, Elem{Name: "b"}))
+	p, err := w.Pos()
+	tt.Pattern(t, `offsets are not known for encoding "utf-16be"`, err.Error())
+	tt.Equals(t, Pos{Line: 2, Col: 4, Offset: -1}, p)
+
+	w = OpenEncoding(&bytes.Buffer{}, "UTF-8", unicode.UTF8.NewEncoder())
+	must(w.Start(Elem{Name: "a"}))
+	tt.Equals(t, Pos{Line: 1, Col: 3, Offset: 2}, pos(t, w))
+
+	w = OpenEncoding(&bytes.Buffer{}, "utf-16be", enc, WithElemSpans(func(ElemSpan) {}))
+	tt.Pattern(t, `offsets are not known`, w.Start(Elem{Name: "a"}).Error())
+}
+
+func TestElemSpans(t *testing.T) {
+	var spans []ElemSpan
+	b, w := open(WithIndent(), WithElemSpans(func(s ElemSpan) {
+		spans = append(spans, s)
+	}))
+	must(w.Start(Doc{}))
+	must(w.Start(Elem{Prefix: "p", URI: "urn:p", Name: "a"}))
+	must(w.Write(Elem{Name: "b"}))
+	must(w.EndAll())
+
+	out := str(b, w)
+	tt.Equals(t, 2, len(spans))
+	tt.Equals(t, "b", spans[0].Name)
+	tt.Equals(t, 2, spans[0].Depth)
+	tt.Equals(t, "<b/>", out[spans[0].Start.Offset:spans[0].End.Offset])
+	tt.Equals(t, Pos{Line: 3, Col: 2, Offset: spans[0].Start.Offset}, spans[0].Start)
+
+	tt.Equals(t, "p", spans[1].Prefix)
+	tt.Equals(t, "a", spans[1].Name)
+	tt.Equals(t, "<p:a xmlns:p=\"urn:p\">\n <b/>\n</p:a>", out[spans[1].Start.Offset:spans[1].End.Offset])
+}
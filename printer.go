@@ -30,7 +30,9 @@ package xmlwriter
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"strings"
 	"unicode/utf8"
 )
@@ -54,16 +56,207 @@ var (
 	escFffd = []byte("\uFFFD") // Unicode replacement character
 )
 
+// printer wraps the bufio.Writer that buffers the output, keeping track of
+// the position of the next byte that will be written.
 type printer struct {
-	*bufio.Writer
+	buf *bufio.Writer
+
+	// counts what the bufio.Writer flushes, and how many writes it took
+	out *byteCounter
+
+	// Limits.MaxBytes, or the context's error, which is returned by every
+	// write after it is set in place of bufio.Writer's own. limited is set
+	// if it's the MaxBytes limit, so the output before it can be flushed.
+	err     error
+	max     int64
+	limited bool
+
+	// Line breaks and the column (in runes) of everything written, see scan.
+	line int
+	col  int
+	cr   bool
+
+	// names which have passed CheckName, kept across Reset
+	names nameCache
+}
+
+func newPrinter(w io.Writer, size int) printer {
+	out := &byteCounter{w: w}
+	return printer{buf: bufio.NewWriterSize(out, size), out: out}
 }
 
-// return the bufio Writer's cached write error
+// return the cached write error, in the style of bufio.Writer
 func (p *printer) cachedWriteError() error {
-	_, err := p.Write(nil)
+	if p.err != nil {
+		return p.err
+	}
+	_, err := p.buf.Write(nil)
 	return err
 }
 
+// Reset discards any buffered output and the cached write error, and
+// switches output to w.
+func (p *printer) Reset(w io.Writer) {
+	p.out.w, p.out.n, p.out.writes = w, 0, 0
+	p.buf.Reset(p.out)
+	p.err = nil
+	p.limited = false
+	p.line, p.col, p.cr = 0, 0, false
+}
+
+// discard throws away any buffered output, and fails every write after it
+// with err.
+func (p *printer) discard(err error) {
+	p.buf.Reset(p.out)
+	p.err = err
+	p.limited = false
+}
+
+// Flush writes any buffered data to the underlying io.Writer.
+func (p *printer) Flush() error {
+	if p.err != nil {
+		if p.limited {
+			// Everything written before the limit was reached still goes
+			// out.
+			if err := p.buf.Flush(); err != nil {
+				return err
+			}
+		}
+		return p.err
+	}
+	return p.buf.Flush()
+}
+
+// scan counts the line breaks in b and finds the column at the end of it,
+// carrying on from the end of the last scan. "\r\n", "\r" and "\n" each count
+// as one line break, as they do for an XML parser, so it doesn't matter
+// which of them the Writer's NewlineString is.
+func (p *printer) scan(b []byte) {
+	if p.cr && len(b) > 0 && b[0] == '\n' {
+		b = b[1:]
+	}
+	p.cr = false
+	if bytes.IndexByte(b, '\r') < 0 {
+		if i := bytes.LastIndexByte(b, '\n'); i >= 0 {
+			p.line += bytes.Count(b[:i+1], newline)
+			p.col = runeStarts(b[i+1:])
+		} else {
+			p.col += runeStarts(b)
+		}
+		return
+	}
+	for i, c := range b {
+		switch {
+		case c == '\r':
+			p.line++
+			p.col = 0
+			p.cr = true
+		case c == '\n':
+			if i == 0 || b[i-1] != '\r' {
+				p.line++
+			}
+			p.col = 0
+			p.cr = false
+		case !utf8.RuneStart(c):
+			// continuation byte, the rune has been counted
+		default:
+			p.col++
+			p.cr = false
+		}
+	}
+}
+
+// scanString is scan for a string.
+func (p *printer) scanString(s string) {
+	if strings.IndexAny(s, "\r\n") >= 0 {
+		// rare enough that it can take the slow way:
+		p.scan([]byte(s))
+		return
+	}
+	if len(s) > 0 {
+		p.cr = false
+	}
+	for i := 0; i < len(s); i++ {
+		if utf8.RuneStart(s[i]) {
+			p.col++
+		}
+	}
+}
+
+var newline = []byte{'\n'}
+
+// runeStarts counts the runes in b by the bytes that start them, so that a
+// rune split between two scans is only counted once.
+func runeStarts(b []byte) (n int) {
+	for _, c := range b {
+		if utf8.RuneStart(c) {
+			n++
+		}
+	}
+	return n
+}
+
+// pos returns the number of line breaks before, and the column (in runes,
+// starting from 0) of, the next byte that will be written.
+func (p *printer) pos() (line, col int) {
+	return p.line, p.col
+}
+
+// Col returns the column (in runes, starting from 0) that the next byte
+// written will appear in.
+func (p *printer) Col() int {
+	return p.col
+}
+
+// Offset returns the number of bytes written to the printer so far.
+func (p *printer) Offset() int64 {
+	return p.out.n + int64(p.buf.Buffered())
+}
+
+// over reports whether writing n more bytes would exceed the MaxBytes
+// limit, in which case the limit becomes the cached write error.
+func (p *printer) over(n int) bool {
+	if p.Offset()+int64(n) > p.max {
+		p.err = &LimitError{Limit: "MaxBytes", Max: p.max}
+		p.limited = true
+		return true
+	}
+	return false
+}
+
+func (p *printer) Write(b []byte) (nn int, err error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	if p.max > 0 && p.over(len(b)) {
+		return 0, p.err
+	}
+	p.scan(b)
+	return p.buf.Write(b)
+}
+
+func (p *printer) WriteString(s string) (nn int, err error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	if p.max > 0 && p.over(len(s)) {
+		return 0, p.err
+	}
+	p.scanString(s)
+	return p.buf.WriteString(s)
+}
+
+func (p *printer) WriteByte(c byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.max > 0 && p.over(1) {
+		return p.err
+	}
+	p.scan([]byte{c})
+	return p.buf.WriteByte(c)
+}
+
 var attrStringEscaped = [256]int{
 	'\'': 1,
 	'!':  1, '#': 1, '$': 1, '%': 1, '(': 1,
@@ -75,90 +268,114 @@ var attrStringEscaped = [256]int{
 	'[': 1, ']': 1, '^': 1, '_': 1, '`': 1, '~': 1,
 }
 
-func (p printer) EscapeAttrString(s string) error {
-	sz := len(s)
-	i := 0
-	for ; i < sz; i++ {
-		if attrStringEscaped[s[i]] == 0 {
-			goto slow
+// textEscaped is attrStringEscaped for text content, where whitespace doesn't
+// need escaping.
+var textEscaped = [256]int{
+	'\t': 1, '\n': 1, '\r': 1, ' ': 1,
+	'!': 1, '#': 1, '$': 1, '%': 1, '(': 1,
+	')': 1, '*': 1, '+': 1, ',': 1, '-': 1, '.': 1, '/': 1,
+	'0': 1, '1': 1, '2': 1, '3': 1, '4': 1, '5': 1, '6': 1, '7': 1, '8': 1, '9': 1,
+	':': 1, ';': 1, '=': 1, '?': 1, '@': 1,
+	'A': 1, 'B': 1, 'C': 1, 'D': 1, 'E': 1, 'F': 1, 'G': 1, 'H': 1, 'I': 1, 'J': 1, 'K': 1, 'L': 1, 'M': 1, 'N': 1, 'O': 1, 'P': 1, 'Q': 1, 'R': 1, 'S': 1, 'T': 1, 'U': 1, 'V': 1, 'W': 1, 'X': 1, 'Y': 1, 'Z': 1,
+	'a': 1, 'b': 1, 'c': 1, 'd': 1, 'e': 1, 'f': 1, 'g': 1, 'h': 1, 'i': 1, 'j': 1, 'k': 1, 'l': 1, 'm': 1, 'n': 1, 'o': 1, 'p': 1, 'q': 1, 'r': 1, 's': 1, 't': 1, 'u': 1, 'v': 1, 'w': 1, 'x': 1, 'y': 1, 'z': 1,
+	'[': 1, '\\': 1, ']': 1, '^': 1, '_': 1, '`': 1, '{': 1, '|': 1, '}': 1, '~': 1,
+}
+
+// escapeRune returns what to write in place of r, which took width bytes in
+// the input, or nil if r can be written as it is. Tabs and line breaks are
+// only escaped in attribute values.
+func escapeRune(r rune, width int, attr bool) []byte {
+	switch r {
+	case '"':
+		return escQuot
+	case '\'':
+		return escApos
+	case '&':
+		return escAmp
+	case '<':
+		return escLt
+	case '>':
+		return escGt
+	case '\t':
+		if attr {
+			return escTab
+		}
+	case '\n':
+		if attr {
+			return escNl
+		}
+	case '\r':
+		if attr {
+			return escCr
+		}
+	default:
+		if !isInCharacterRange(r) || (r == 0xFFFD && width == 1) {
+			return escFffd
 		}
 	}
-	p.WriteString(s)
 	return nil
+}
 
-slow:
-	var esc []byte
-	p.WriteString(s[:i])
+func (p *printer) EscapeAttrString(s string) error {
+	return p.escapeString(s, &attrStringEscaped, true)
+}
 
-	last := i
-	for i < sz {
+func (p *printer) EscapeString(s string) error {
+	return p.escapeString(s, &textEscaped, false)
+}
+
+// EscapeAttrBytes is EscapeAttrString for a byte slice.
+func (p *printer) EscapeAttrBytes(s []byte) error {
+	return p.escapeBytes(s, &attrStringEscaped, true)
+}
+
+// EscapeBytes is EscapeString for a byte slice.
+func (p *printer) EscapeBytes(s []byte) error {
+	return p.escapeBytes(s, &textEscaped, false)
+}
+
+// escapeString writes s, escaped by escapeRune. Bytes marked in safe never
+// need escaping, so s is only decoded from the first byte that isn't.
+func (p *printer) escapeString(s string, safe *[256]int, attr bool) error {
+	i := 0
+	for i < len(s) && safe[s[i]] != 0 {
+		i++
+	}
+	last := 0
+	for i < len(s) {
 		r, width := utf8.DecodeRuneInString(s[i:])
 		i += width
-		switch r {
-		case '"':
-			esc = escQuot
-		case '\'':
-			esc = escApos
-		case '&':
-			esc = escAmp
-		case '<':
-			esc = escLt
-		case '>':
-			esc = escGt
-		case '\t':
-			esc = escTab
-		case '\n':
-			esc = escNl
-		case '\r':
-			esc = escCr
-		default:
-			if !isInCharacterRange(r) || (r == 0xFFFD && width == 1) {
-				esc = escFffd
-				break
-			}
-			continue
+		if esc := escapeRune(r, width, attr); esc != nil {
+			p.WriteString(s[last : i-width])
+			p.Write(esc)
+			last = i
 		}
-		p.WriteString(s[last : i-width])
-		p.Write(esc)
-		last = i
 	}
 	p.WriteString(s[last:])
-	return nil
+	return p.cachedWriteError()
 }
 
-func (p printer) EscapeString(s string) error {
-	var esc []byte
+// escapeBytes is escapeString for a byte slice.
+func (p *printer) escapeBytes(s []byte, safe *[256]int, attr bool) error {
+	i := 0
+	for i < len(s) && safe[s[i]] != 0 {
+		i++
+	}
 	last := 0
-	for i := 0; i < len(s); {
-		r, width := utf8.DecodeRuneInString(s[i:])
+	for i < len(s) {
+		r, width := utf8.DecodeRune(s[i:])
 		i += width
-		switch r {
-		case '"':
-			esc = escQuot
-		case '\'':
-			esc = escApos
-		case '&':
-			esc = escAmp
-		case '<':
-			esc = escLt
-		case '>':
-			esc = escGt
-		default:
-			if !isInCharacterRange(r) || (r == 0xFFFD && width == 1) {
-				esc = escFffd
-				break
-			}
-			continue
+		if esc := escapeRune(r, width, attr); esc != nil {
+			p.Write(s[last : i-width])
+			p.Write(esc)
+			last = i
 		}
-		p.WriteString(s[last : i-width])
-		p.Write(esc)
-		last = i
 	}
-	p.WriteString(s[last:])
-	return nil
+	p.Write(s[last:])
+	return p.cachedWriteError()
 }
 
-func (p printer) writeExternalID(publicID string, systemID string, enforce bool) error {
+func (p *printer) writeExternalID(publicID string, systemID string, enforce bool) error {
 	// 'SYSTEM' S SystemLiteral | 'PUBLIC' S PubidLiteral S SystemLiteral
 
 	if systemID != "" && publicID == "" {
@@ -181,7 +398,7 @@ func (p printer) writeExternalID(publicID string, systemID string, enforce bool)
 	return nil
 }
 
-func (p printer) writeSystemID(systemID string, enforce bool) error {
+func (p *printer) writeSystemID(systemID string, enforce bool) error {
 	// SystemLiteral ::= ('"' [^"]* '"') | ("'" [^']* "'")
 
 	dq := strings.IndexRune(systemID, '"')
@@ -203,7 +420,7 @@ func (p printer) writeSystemID(systemID string, enforce bool) error {
 	return p.cachedWriteError()
 }
 
-func (p printer) writeEntityValue(value string, enforce bool) error {
+func (p *printer) writeEntityValue(value string, enforce bool) error {
 	// EntityValue ::= '"' ([^%&"] | PEReference | Reference)* '"'
 	//              |  "'" ([^%&'] | PEReference | Reference)* "'"
 	dq := strings.IndexRune(value, '"')
@@ -225,7 +442,7 @@ func (p printer) writeEntityValue(value string, enforce bool) error {
 	return p.cachedWriteError()
 }
 
-func (p printer) writePublicID(publicID string, systemID string, enforce bool) error {
+func (p *printer) writePublicID(publicID string, systemID string, enforce bool) error {
 	if enforce {
 		if len(publicID) < 0 {
 			return fmt.Errorf("xmlwriter: public ID must not be empty")
@@ -246,14 +463,20 @@ func (p printer) writePublicID(publicID string, systemID string, enforce bool) e
 	return err
 }
 
-func (p printer) printAttr(name, value string, enforce bool) error {
+func (p *printer) printAttr(name, value string, enforce bool) error {
 	// this is shared with Doc to write version="1.0", etc
 	if enforce {
-		if err := CheckName(name); err != nil {
+		if err := p.names.check(name); err != nil {
 			return err
 		}
 	}
 	p.WriteByte(' ')
+	return p.printAttrBare(name, value)
+}
+
+// printAttrBare prints an attribute without the leading space. Used when
+// the indenter has already placed the attribute on a new line.
+func (p *printer) printAttrBare(name, value string) error {
 	p.WriteString(name)
 	p.WriteString(`="`)
 	p.EscapeAttrString(value)
@@ -1,7 +1,6 @@
 package xmlwriter
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"io/ioutil"
@@ -16,7 +15,7 @@ func TestEscapeAttrString(t *testing.T) {
 	ws := func(t *testing.T, in string) (out string) {
 		t.Helper()
 		var b bytes.Buffer
-		p := printer{Writer: bufio.NewWriterSize(&b, 2048)}
+		p := newPrinter(&b, 2048)
 		p.EscapeAttrString(in)
 		p.Flush()
 		if err := p.cachedWriteError(); err != nil {
@@ -36,6 +35,44 @@ func TestEscapeAttrString(t *testing.T) {
 	expect(t, "a\n", "a&#xA;")
 }
 
+func TestEscapeString(t *testing.T) {
+	for in, out := range map[string]string{
+		"abc":        "abc",
+		"a b\tc\r\n": "a b\tc\r\n",
+		"a'b":        "a&#39;b",
+		"a<b & c>":   "a&lt;b &amp; c&gt;",
+		"r\u00e9s'":  "r\u00e9s&#39;",
+		"a\x00b":     "a\uFFFDb",
+	} {
+		var b bytes.Buffer
+		p := newPrinter(&b, 2048)
+		tt.OK(t, p.EscapeString(in))
+		tt.OK(t, p.Flush())
+		tt.Equals(t, out, b.String())
+	}
+}
+
+func TestEscapeBytes(t *testing.T) {
+	escape := func(fn func(p *printer)) string {
+		var b bytes.Buffer
+		p := newPrinter(&b, 2048)
+		fn(&p)
+		tt.OK(t, p.Flush())
+		return b.String()
+	}
+
+	for _, in := range []string{
+		"", "abc", "a-c", "a\nb", "\tb\r", `<"a" & 'b'>`, "r\u00e9sum\u00e9", "\x00bad\xffutf8", "\U0001F600",
+	} {
+		tt.Equals(t,
+			escape(func(p *printer) { p.EscapeAttrString(in) }),
+			escape(func(p *printer) { p.EscapeAttrBytes([]byte(in)) }))
+		tt.Equals(t,
+			escape(func(p *printer) { p.EscapeString(in) }),
+			escape(func(p *printer) { p.EscapeBytes([]byte(in)) }))
+	}
+}
+
 func TestIsInCharacterRange(t *testing.T) {
 	invalid := []rune{
 		utf8.MaxRune + 1,
@@ -53,7 +90,7 @@ func TestIsInCharacterRange(t *testing.T) {
 func BenchmarkEscapeAttrString(b *testing.B) {
 	for _, sz := range []int{10, 50, 300} {
 		b.Run(fmt.Sprintf("ascii-%d", sz), func(b *testing.B) {
-			p := printer{Writer: bufio.NewWriterSize(ioutil.Discard, 2048)}
+			p := newPrinter(ioutil.Discard, 2048)
 			v := strings.Repeat("1", sz)
 
 			for i := 0; i < b.N; i++ {
@@ -63,7 +100,7 @@ func BenchmarkEscapeAttrString(b *testing.B) {
 		})
 
 		b.Run(fmt.Sprintf("utf8-first-%d", sz), func(b *testing.B) {
-			p := printer{Writer: bufio.NewWriterSize(ioutil.Discard, 2048)}
+			p := newPrinter(ioutil.Discard, 2048)
 			v := "\uD000" + strings.Repeat("1", sz-1)
 
 			for i := 0; i < b.N; i++ {
@@ -73,7 +110,7 @@ func BenchmarkEscapeAttrString(b *testing.B) {
 		})
 
 		b.Run(fmt.Sprintf("utf8-last-%d", sz), func(b *testing.B) {
-			p := printer{Writer: bufio.NewWriterSize(ioutil.Discard, 2048)}
+			p := newPrinter(ioutil.Discard, 2048)
 			v := strings.Repeat("1", sz-1) + "\uD000"
 
 			for i := 0; i < b.N; i++ {
@@ -0,0 +1,56 @@
+package xmlwriter
+
+import "io"
+
+// Stats contains counts of what a Writer has written, see Writer.Stats.
+type Stats struct {
+	// Number of each kind of node written. Attrs doesn't include namespace
+	// declarations made by Elem.URI or Attr.URI.
+	Elems    int
+	Attrs    int
+	Texts    int
+	Comments int
+	CDatas   int
+
+	// Bytes is the number of bytes of XML written so far, before encoding,
+	// including any which haven't been flushed yet.
+	Bytes int64
+
+	// EncodedBytes is the number of bytes flushed to the io.Writer passed
+	// to Open or OpenEncoding, after encoding.
+	EncodedBytes int64
+
+	// The deepest Depth the Writer has reached.
+	MaxDepth int
+
+	// Number of times buffered output has been written to the io.Writer.
+	Flushes int
+}
+
+// Stats returns counts of what the Writer has written so far.
+func (w *Writer) Stats() Stats {
+	s := w.stats
+	s.Bytes = w.printer.Offset()
+	s.EncodedBytes = w.printer.out.n
+	if w.encoded != nil {
+		s.EncodedBytes = w.encoded.n
+	}
+	s.Flushes = w.printer.out.writes
+	return s
+}
+
+// byteCounter counts the bytes written to an io.Writer, and the number of
+// writes. One sits under the printer's bufio.Writer, and another between an
+// encoder and the underlying io.Writer.
+type byteCounter struct {
+	w      io.Writer
+	n      int64
+	writes int
+}
+
+func (c *byteCounter) Write(b []byte) (n int, err error) {
+	n, err = c.w.Write(b)
+	c.n += int64(n)
+	c.writes++
+	return n, err
+}
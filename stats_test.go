@@ -0,0 +1,66 @@
+package xmlwriter
+
+import (
+	"bytes"
+	"testing"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestStats(t *testing.T) {
+	b, w := open()
+	ec := &ErrCollector{}
+	ec.Must(
+		w.Start(Elem{Name: "a", Attrs: []Attr{{Name: "x", Value: "1"}}}),
+		w.Write(
+			Elem{Name: "b", Content: []Writable{Text("hi"), Elem{Name: "c"}}},
+			Comment{Content: "c"},
+			CData{Content: "d"},
+			Text("e"),
+		),
+		w.WriteAttr(),
+	)
+	s := w.Stats()
+	tt.Equals(t, Stats{
+		Elems: 3, Attrs: 1, Texts: 2, Comments: 1, CDatas: 1,
+		Bytes: 44, MaxDepth: 2,
+	}, s)
+
+	ec.Must(w.EndAllFlush())
+	tt.Equals(t, `<a x="1"><b>hi<c/></b><!--c--><![CDATA[d]]>e</a>`, b.String())
+	s = w.Stats()
+	tt.Equals(t, int64(b.Len()), s.Bytes)
+	tt.Equals(t, int64(b.Len()), s.EncodedBytes)
+	tt.Equals(t, 1, s.Flushes)
+}
+
+func TestStatsRejectedAttr(t *testing.T) {
+	for _, bad := range []Attr{
+		{Name: "1bad"},
+		{Prefix: "a", Name: "x", URI: "urn:other"},
+	} {
+		_, w := open(WithLimits(Limits{MaxAttrs: 2}))
+		tt.OK(t, w.Start(Elem{Prefix: "a", Name: "e", URI: "urn:a"}))
+		tt.OK(t, w.WriteAttr(Attr{Name: "ok"}))
+		tt.Assert(t, w.WriteAttr(bad) != nil)
+
+		// neither counts towards Stats.Attrs or Limits.MaxAttrs:
+		tt.Equals(t, 1, w.Stats().Attrs)
+		tt.Equals(t, 1, w.nodes[w.current].attrs)
+	}
+}
+
+func TestStatsEncoded(t *testing.T) {
+	b := &bytes.Buffer{}
+	enc := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder()
+	w := OpenEncoding(b, "utf-16be", enc, func(w *Writer) { w.InitialBufSize = 4 })
+	tt.OK(t, w.WriteElem(Elem{Name: "foo", Content: []Writable{Text("bar")}}))
+	tt.OK(t, w.Flush())
+
+	s := w.Stats()
+	tt.Equals(t, int64(len("<foo>bar</foo>")), s.Bytes)
+	tt.Equals(t, int64(b.Len()), s.EncodedBytes)
+	tt.Equals(t, 2*s.Bytes, s.EncodedBytes)
+	tt.Assert(t, s.Flushes > 1)
+}
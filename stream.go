@@ -0,0 +1,226 @@
+package xmlwriter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// TextWriter returns an io.WriteCloser which writes everything written to it
+// as text inside the current node, escaping it as it goes. It can be used to
+// copy large amounts of text into an element without holding it all in
+// memory:
+//	tw := w.TextWriter()
+//	io.Copy(tw, f)
+//	tw.Close()
+//
+// A UTF-8 sequence split across two calls to Write is held back until it is
+// complete, so that it is escaped correctly. Each call to Write may write
+// a separate Text node, so Listeners see one Event per call, and MaxTextLen
+// applies to each call rather than to the whole text.
+//
+// Nothing else may be written to the Writer until the TextWriter is closed.
+// Close writes anything which has been held back.
+func (w *Writer) TextWriter() io.WriteCloser {
+	return &contentWriter{w: w, kind: TextNode}
+}
+
+// CDataWriter starts a CData node and returns an io.WriteCloser which writes
+// its content, see TextWriter. As well as UTF-8 sequences, a "]" or "]]" at
+// the end of a Write is held back until the next one, so that a "]]>" split
+// between calls is caught. Close ends the CData node.
+//
+// If the CData can't be started, the error is returned by Write and Close.
+func (w *Writer) CDataWriter() io.WriteCloser {
+	return &contentWriter{w: w, kind: CDataContentNode, err: w.StartCData(CData{})}
+}
+
+// CommentWriter starts a Comment node and returns an io.WriteCloser which
+// writes its content, see TextWriter. As well as UTF-8 sequences, a "-" at
+// the end of a Write is held back until the next one, so that a "--" split
+// between calls is caught. Close ends the Comment node; if Enforce is set, it
+// fails if the comment ends with "-".
+//
+// If the Comment can't be started, the error is returned by Write and Close.
+func (w *Writer) CommentWriter() io.WriteCloser {
+	return &contentWriter{w: w, kind: CommentContentNode, err: w.StartComment(Comment{})}
+}
+
+// WriteTextFrom copies everything read from r into a text node inside the
+// current node, escaping it as it goes, until EOF. Memory use is bounded no
+// matter how much is read. It returns the number of bytes read. See
+// TextWriter.
+func (w *Writer) WriteTextFrom(r io.Reader) (n int64, err error) {
+	if err := w.enter(); err != nil {
+		return 0, err
+	}
+	defer w.exit(&err, "WriteTextFrom", nil)
+
+	return readFromClose(w.TextWriter(), r)
+}
+
+// WriteCDataFrom writes a CData node containing everything read from r,
+// validating it as it goes, until EOF. See WriteTextFrom and CDataWriter.
+func (w *Writer) WriteCDataFrom(r io.Reader) (n int64, err error) {
+	if err := w.enter(); err != nil {
+		return 0, err
+	}
+	defer w.exit(&err, "WriteCDataFrom", nil)
+
+	return readFromClose(w.CDataWriter(), r)
+}
+
+// WriteCommentFrom writes a Comment node containing everything read from r,
+// validating it as it goes, until EOF. See WriteTextFrom and CommentWriter.
+func (w *Writer) WriteCommentFrom(r io.Reader) (n int64, err error) {
+	if err := w.enter(); err != nil {
+		return 0, err
+	}
+	defer w.exit(&err, "WriteCommentFrom", nil)
+
+	return readFromClose(w.CommentWriter(), r)
+}
+
+func readFromClose(wc io.WriteCloser, r io.Reader) (n int64, err error) {
+	n, err = io.Copy(wc, r)
+	if err != nil {
+		return n, err
+	}
+	return n, wc.Close()
+}
+
+// contentWriter implements TextWriter, CDataWriter and CommentWriter.
+type contentWriter struct {
+	w    *Writer
+	kind NodeKind
+	err  error
+
+	// bytes held back from the end of the last Write, see holdBack.
+	carry  [utf8.UTFMax - 1]byte
+	ncarry int
+
+	// used to join carry to the start of the next Write
+	buf [2*utf8.UTFMax - 1]byte
+
+	closed bool
+}
+
+func (c *contentWriter) Write(p []byte) (n int, err error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.closed {
+		return 0, fmt.Errorf("xmlwriter: %s writer is closed", c.kind.Name())
+	}
+
+	n = len(p)
+
+	// Anything held back is joined to the start of p a rune at a time, so
+	// only that much of p is copied:
+	for c.ncarry > 0 && len(p) > 0 {
+		_, size := utf8.DecodeRune(p)
+		joined := append(append(c.buf[:0], c.carry[:c.ncarry]...), p[:size]...)
+		p = p[size:]
+		if err := c.writeHeld(joined); err != nil {
+			return 0, err
+		}
+	}
+	if len(p) > 0 {
+		if err := c.writeHeld(p); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// writeHeld writes data, except for the bytes at the end of it which have to
+// be held back, which are kept in carry.
+func (c *contentWriter) writeHeld(data []byte) error {
+	if c.kind == CommentContentNode && c.w.Enforce && bytes.Contains(data, []byte("--")) {
+		// the held back "-" could be the first half of this, so it
+		// wouldn't be caught by WriteCommentContentBytes:
+		c.err = fmt.Errorf("xmlwriter: comment may not contain '--'")
+		return c.err
+	}
+	end := len(data) - c.holdBack(data)
+	if err := c.write(data[:end]); err != nil {
+		c.err = err
+		return err
+	}
+	c.ncarry = copy(c.carry[:], data[end:])
+	return nil
+}
+
+// holdBack returns the number of bytes at the end of data which can't be
+// written until more has arrived: an incomplete UTF-8 sequence, or the start
+// of a sequence which isn't allowed in CData or Comment content.
+func (c *contentWriter) holdBack(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-(utf8.UTFMax-1); i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return len(data) - i
+			}
+			break
+		}
+	}
+
+	var held byte
+	max := 0
+	switch c.kind {
+	case CDataContentNode:
+		held, max = ']', 2
+	case CommentContentNode:
+		held, max = '-', 1
+	}
+	n := 0
+	for n < max && n < len(data) && data[len(data)-1-n] == held {
+		n++
+	}
+	return n
+}
+
+func (c *contentWriter) write(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	switch c.kind {
+	case CDataContentNode:
+		return c.w.WriteCDataContentBytes(b)
+	case CommentContentNode:
+		return c.w.WriteCommentContentBytes(b)
+	default:
+		return c.w.WriteTextBytes(b)
+	}
+}
+
+// Close writes anything that has been held back, then ends the CData or
+// Comment node.
+func (c *contentWriter) Close() error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	if c.kind == CommentContentNode && c.w.Enforce && c.ncarry > 0 && c.carry[c.ncarry-1] == '-' {
+		// it would run into the "-->" that ends the comment:
+		c.err = fmt.Errorf("xmlwriter: comment may not end with '-'")
+		return c.err
+	}
+	if err := c.write(c.carry[:c.ncarry]); err != nil {
+		c.err = err
+		return err
+	}
+	c.ncarry = 0
+
+	switch c.kind {
+	case CDataContentNode:
+		c.err = c.w.EndCData()
+	case CommentContentNode:
+		c.err = c.w.EndComment()
+	}
+	return c.err
+}
@@ -0,0 +1,145 @@
+package xmlwriter
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+)
+
+func writeOneByte(t *testing.T, wc io.WriteCloser, s string) error {
+	t.Helper()
+	_, err := io.Copy(wc, iotest.OneByteReader(strings.NewReader(s)))
+	return err
+}
+
+func TestTextWriter(t *testing.T) {
+	b, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "foo"}))
+	tw := w.TextWriter()
+	tt.OK(t, writeOneByte(t, tw, "résumé < 😀 & \xff"))
+	tt.OK(t, tw.Close())
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, "<foo>résumé &lt; 😀 &amp; �</foo>", str(b, w))
+}
+
+func TestTextWriterPartialRuneAtClose(t *testing.T) {
+	b, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "foo"}))
+	tw := w.TextWriter()
+	_, err := tw.Write([]byte("a\xe2\x82"))
+	tt.OK(t, err)
+	tt.OK(t, tw.Close())
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, "<foo>a��</foo>", str(b, w))
+}
+
+func TestCDataWriter(t *testing.T) {
+	b, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "foo"}))
+	cw := w.CDataWriter()
+	tt.OK(t, writeOneByte(t, cw, "a]] > ]]]x ü"))
+	tt.OK(t, cw.Close())
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, "<foo><![CDATA[a]] > ]]]x ü]]></foo>", str(b, w))
+}
+
+func TestCDataWriterEndSplit(t *testing.T) {
+	_, w := open()
+	cw := w.CDataWriter()
+	_, err := cw.Write([]byte("a]"))
+	tt.OK(t, err)
+	_, err = cw.Write([]byte("]"))
+	tt.OK(t, err)
+	_, err = cw.Write([]byte(">b"))
+	tt.Pattern(t, `may not contain ']]>'`, err.Error())
+	tt.Equals(t, err, cw.Close())
+
+	// the held back "]" is joined to the start of the next Write:
+	_, w = open()
+	cw = w.CDataWriter()
+	_, err = cw.Write([]byte("a]"))
+	tt.OK(t, err)
+	_, err = cw.Write([]byte("]>b"))
+	tt.Pattern(t, `may not contain ']]>'`, err.Error())
+}
+
+func TestCommentWriter(t *testing.T) {
+	b, w := open()
+	cw := w.CommentWriter()
+	tt.OK(t, writeOneByte(t, cw, "a - b -> c"))
+	tt.OK(t, cw.Close())
+	tt.Equals(t, "<!--a - b -> c-->", str(b, w))
+
+	_, w = open()
+	cw = w.CommentWriter()
+	tt.Pattern(t, `may not contain '--'`, writeOneByte(t, cw, "a--b").Error())
+
+	_, w = open()
+	cw = w.CommentWriter()
+	_, err := cw.Write([]byte("a-"))
+	tt.OK(t, err)
+	tt.Pattern(t, `may not end with '-'`, cw.Close().Error())
+}
+
+func TestStreamWriterClosed(t *testing.T) {
+	_, w := open()
+	cw := w.CommentWriter()
+	tt.OK(t, cw.Close())
+	tt.OK(t, cw.Close())
+	_, err := cw.Write([]byte("a"))
+	tt.Pattern(t, `closed`, err.Error())
+}
+
+func TestStreamWriterStartFails(t *testing.T) {
+	_, w := open()
+	tt.OK(t, w.StartComment(Comment{}))
+	cw := w.CDataWriter()
+	_, err := cw.Write([]byte("a"))
+	tt.Pattern(t, `unexpected kind`, err.Error())
+	tt.Equals(t, err, cw.Close())
+}
+
+func TestWriteTextFrom(t *testing.T) {
+	in := strings.Repeat("a < b & ü ", 10000)
+	b, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "foo"}))
+	n, err := w.WriteTextFrom(iotest.HalfReader(strings.NewReader(in)))
+	tt.OK(t, err)
+	tt.Equals(t, int64(len(in)), n)
+	tt.OK(t, w.EndAll())
+	tt.Equals(t, "<foo>"+strings.Repeat("a &lt; b &amp; ü ", 10000)+"</foo>", str(b, w))
+}
+
+func TestWriteCDataFrom(t *testing.T) {
+	b, w := open()
+	n, err := w.WriteCDataFrom(iotest.OneByteReader(strings.NewReader("a]]b")))
+	tt.OK(t, err)
+	tt.Equals(t, int64(4), n)
+	tt.Equals(t, "<![CDATA[a]]b]]>", str(b, w))
+
+	_, w = open()
+	_, err = w.WriteCDataFrom(iotest.OneByteReader(strings.NewReader("a]]>b")))
+	tt.Pattern(t, `may not contain ']]>'`, err.Error())
+	tt.Equals(t, err, w.Err())
+}
+
+func TestWriteCommentFrom(t *testing.T) {
+	b, w := open()
+	_, err := w.WriteCommentFrom(strings.NewReader("yep"))
+	tt.OK(t, err)
+	tt.Equals(t, "<!--yep-->", str(b, w))
+
+	_, w = open()
+	_, err = w.WriteCommentFrom(iotest.OneByteReader(strings.NewReader("a\x00")))
+	tt.Pattern(t, `invalid chars`, err.Error())
+}
+
+func TestWriteTextFromReadError(t *testing.T) {
+	_, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "foo"}))
+	_, err := w.WriteTextFrom(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("ab"))))
+	tt.Equals(t, iotest.ErrTimeout, err)
+}
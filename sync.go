@@ -0,0 +1,90 @@
+package xmlwriter
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SyncWriter lets several goroutines share a Writer, each writing complete
+// subtrees into the Writer's current node without interleaving, like log
+// shippers appending <event> records to one long-lived stream:
+//	w := xmlwriter.Open(f)
+//	w.StartElem(xmlwriter.Elem{Name: "events"})
+//	sw := xmlwriter.NewSyncWriter(w)
+//
+//	// on any number of goroutines:
+//	err := sw.WriteElem(xmlwriter.Elem{Name: "event", Content: ...})
+//
+// The Writer must not be used directly while the SyncWriter is in use. Once a
+// call has failed, the Writer's error is returned by every call, see
+// Writer.Err.
+//
+// To generate a large document faster using several goroutines, rather than
+// to share one between producers, see Writer.Child.
+type SyncWriter struct {
+	mu sync.Mutex
+	w  *Writer
+}
+
+// NewSyncWriter creates a SyncWriter which writes to w.
+func NewSyncWriter(w *Writer) *SyncWriter {
+	return &SyncWriter{w: w}
+}
+
+// Do calls fn with the Writer, and no other goroutine can use the SyncWriter
+// until fn returns. Every node fn starts must be ended before it returns,
+// even if it returns an error, and fn must not end any others; if not, the
+// Writer fails, as anything written afterwards would end up in the wrong
+// node. The error returned by fn is returned by Do.
+func (s *SyncWriter) Do(fn func(w *Writer) error) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := s.w
+	if w.err != nil {
+		return w.failed
+	}
+	depth := w.current
+	err = fn(w)
+	if w.current != depth {
+		var unbalanced error
+		if w.current > depth {
+			unbalanced = fmt.Errorf("xmlwriter: SyncWriter.Do did not end %s node", w.nodes[w.current].kind.Name())
+		} else {
+			unbalanced = fmt.Errorf("xmlwriter: SyncWriter.Do ended a node it did not start")
+		}
+		w.latch(&unbalanced)
+		if err == nil {
+			err = unbalanced
+		}
+	}
+	return err
+}
+
+// Write calls Writer.Write with the SyncWriter locked.
+func (s *SyncWriter) Write(nodes ...Writable) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(nodes...)
+}
+
+// WriteElem calls Writer.WriteElem with the SyncWriter locked.
+func (s *SyncWriter) WriteElem(elem Elem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.WriteElem(elem)
+}
+
+// Block calls Writer.Block with the SyncWriter locked.
+func (s *SyncWriter) Block(start Startable, nodes ...Writable) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Block(start, nodes...)
+}
+
+// Flush calls Writer.Flush with the SyncWriter locked.
+func (s *SyncWriter) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Flush()
+}
@@ -0,0 +1,83 @@
+package xmlwriter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+)
+
+func TestSyncWriter(t *testing.T) {
+	b, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "events"}))
+	sw := NewSyncWriter(w)
+
+	errs := make(chan error, 800)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				errs <- sw.Do(func(w *Writer) error {
+					ec := &ErrCollector{}
+					ec.Do(
+						w.StartElem(Elem{Name: "event"}),
+						w.WriteText("a"),
+						w.WriteElem(Elem{Name: "b"}),
+						w.EndElem("event"),
+					)
+					return ec.Unwrap()
+				})
+				errs <- sw.Block(Elem{Name: "event"}, Text("a"), Elem{Name: "b"})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		tt.OK(t, err)
+	}
+	tt.OK(t, sw.Flush())
+	tt.OK(t, w.EndAll())
+
+	out := str(b, w)
+	tt.Equals(t, 800, strings.Count(out, "<event>a<b/></event>"))
+	tt.Equals(t, len("<events></events>")+800*len("<event>a<b/></event>"), len(out))
+}
+
+func TestSyncWriterUnended(t *testing.T) {
+	_, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "events"}))
+	sw := NewSyncWriter(w)
+
+	err := sw.Do(func(w *Writer) error {
+		return w.StartElem(Elem{Name: "event"})
+	})
+	tt.Pattern(t, `did not end elem`, err.Error())
+	tt.Pattern(t, `already failed`, sw.WriteElem(Elem{Name: "event"}).Error())
+
+	_, w = open()
+	tt.OK(t, w.StartElem(Elem{Name: "events"}))
+	err = NewSyncWriter(w).Do(func(w *Writer) error { return w.EndAll() })
+	tt.Pattern(t, `ended a node it did not start`, err.Error())
+}
+
+func TestSyncWriterUnendedError(t *testing.T) {
+	b, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "events"}))
+	sw := NewSyncWriter(w)
+
+	err := sw.Do(func(w *Writer) error {
+		if err := w.StartElem(Elem{Name: "event"}); err != nil {
+			return err
+		}
+		return fmt.Errorf("producer failed")
+	})
+	tt.Pattern(t, `producer failed`, err.Error())
+	tt.Pattern(t, `did not end elem`, w.Err().Error())
+	tt.Pattern(t, `already failed`, sw.WriteElem(Elem{Name: "event2"}).Error())
+	tt.Assert(t, !strings.Contains(b.String(), "event2"))
+}
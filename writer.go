@@ -1,9 +1,10 @@
 package xmlwriter
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 
 	"golang.org/x/text/encoding"
@@ -15,6 +16,10 @@ const (
 )
 
 // Writer writes XML to an io.Writer.
+//
+// A Writer is not safe for concurrent use by multiple goroutines. See
+// SyncWriter to share one between goroutines, and Writer.Child to write parts
+// of a document concurrently.
 type Writer struct {
 	printer  printer
 	nodes    []node
@@ -23,6 +28,61 @@ type Writer struct {
 
 	last Event
 
+	// the first error returned by any of the Writer's methods, see Err.
+	// failed wraps it and is returned by every subsequent call.
+	err    error
+	failed error
+
+	// attributes waiting to be sorted while an element is open, see
+	// AttrOrder.
+	attrs   []Attr
+	attrBuf [8]Attr
+
+	// set by an indenter that has moved the next attribute on to its own
+	// line, so the separating space can be left out.
+	attrIndented bool
+
+	// used to format values by WriteAttrInt, WriteTextInt, etc.
+	scratch [64]byte
+
+	// see WithDebug.
+	debug DebugLogger
+
+	// calls counts the public methods currently running, see enter.
+	// callStart is the offset the outermost one started from, and
+	// callDepth, callAttrs and callChildren the state of the node stack.
+	calls        int
+	callStart    int64
+	callDepth    int
+	callAttrs    int
+	callChildren int
+
+	stats   Stats
+	encoded *byteCounter
+
+	// set by OpenEncoding, so Reset can start it again.
+	encoder *encoding.Encoder
+
+	limits Limits
+
+	// see WithContext. done is nil if there is no context to check.
+	ctx  context.Context
+	done <-chan struct{}
+
+	listeners []Listener
+
+	// set while compiling a Fragment, see NewFragment.
+	fragment *Fragment
+
+	// set on a Writer returned by Child. floor is the index of the deepest
+	// node inherited from the parent, which can't be ended; it is -1
+	// otherwise.
+	child *childState
+	floor int
+
+	// offset of the start of the leaf node being written, for Event.Bytes.
+	leafStart int64
+
 	// Perform validation on output. Defaults to true when created using Open().
 	Enforce bool
 
@@ -43,6 +103,19 @@ type Writer struct {
 
 	// Controls the indenting process used by the writer.
 	Indenter Indenter
+
+	// If greater than zero, content written by WriteBase64 and
+	// Base64Writer is broken into lines of at most this many characters,
+	// counting from the start of the line. Each new line is indented to
+	// match the Indenter, if it is a StandardIndenter or built on one. See
+	// WithBinaryWrap.
+	BinaryWrap int
+
+	// Controls the order attributes are written in. If this is anything
+	// other than AttrOrderNone, attributes (and namespace declarations)
+	// are held back while an element is open and written in sorted order
+	// when it is opened. Raw nodes written in between are not held back.
+	AttrOrder AttrOrder
 }
 
 // Option is an option to the Writer.
@@ -68,9 +141,30 @@ func WithIndentString(indent string) Option {
 	}
 }
 
+// WithWrap configures the Writer with a WrappingIndenter, which indents like
+// the StandardIndenter and also wraps text and comments to fit within width
+// characters where possible:
+//	w := xmlwriter.Open(b, xmlwriter.WithWrap(80))
+func WithWrap(width int) Option {
+	return func(w *Writer) {
+		w.Indenter = NewWrappingIndenter(width)
+	}
+}
+
+// WithAttrOrder configures the Writer to sort each element's attributes
+// before writing them, for output that doesn't depend on the order of calls:
+//	w := xmlwriter.Open(b, xmlwriter.WithAttrOrder(xmlwriter.AttrOrderName))
+func WithAttrOrder(order AttrOrder) Option {
+	return func(w *Writer) {
+		w.AttrOrder = order
+	}
+}
+
 func newWriter(w io.Writer, options ...Option) *Writer {
 	xw := &Writer{}
 	xw.current = -1
+	xw.floor = -1
+	xw.attrs = xw.attrBuf[:0]
 	xw.NewlineString = "\n"
 	xw.nodes = make([]node, initialNodeDepth)
 	xw.Enforce = true
@@ -81,7 +175,8 @@ func newWriter(w io.Writer, options ...Option) *Writer {
 	if xw.InitialBufSize <= 0 {
 		xw.InitialBufSize = defaultBufsize
 	}
-	xw.printer = printer{Writer: bufio.NewWriterSize(w, xw.InitialBufSize)}
+	xw.printer = newPrinter(w, xw.InitialBufSize)
+	xw.printer.max = xw.limits.MaxBytes
 	return xw
 }
 
@@ -103,12 +198,71 @@ func Open(w io.Writer, options ...Option) *Writer {
 // on the fly to the target encoding.
 //
 func OpenEncoding(w io.Writer, encstr string, encoder *encoding.Encoder, options ...Option) *Writer {
-	enc := encoding.HTMLEscapeUnsupported(encoder).Writer(w)
-	xw := newWriter(enc, options...)
+	encoded := &byteCounter{w: w}
+	encoder = encoding.HTMLEscapeUnsupported(encoder)
+	xw := newWriter(encoder.Writer(encoded), options...)
 	xw.encoding = encstr
+	xw.encoder = encoder
+	xw.encoded = encoded
 	return xw
 }
 
+// Reset discards all of the Writer's state, including any output which has
+// not been flushed and the error returned by Err, and switches output to w.
+// The Writer can then be used to write a new document to w, reusing the
+// memory it has already allocated.
+//
+// The Writer's configuration is kept: its fields, encoding, Limits, Listeners
+// and debug logging remain as they were. The Indenter is reset if it has a
+// Reset() method, as StandardIndenter and the indenters built on it do.
+// Listeners are not reset; one which holds state for a single document, like
+// xmlindex.Builder or the one added by WithElemSpans, must be replaced using
+// a new Writer. The context set by WithContext is dropped, as it belongs to
+// the previous document.
+//
+// This makes it safe to keep Writers in a sync.Pool, as long as a Writer is
+// only put back once nothing else is using it:
+//
+//	var pool = sync.Pool{New: func() interface{} {
+//		return xmlwriter.Open(nil, xmlwriter.WithIndent())
+//	}}
+//
+//	w := pool.Get().(*xmlwriter.Writer)
+//	w.Reset(rw)
+//	defer pool.Put(w)
+//
+func (w *Writer) Reset(out io.Writer) {
+	dst := out
+	if w.encoded != nil {
+		// the encoder can hold state, like whether it has written a BOM, so
+		// it is reset along with the writer wrapped around it:
+		w.encoded.w, w.encoded.n = out, 0
+		dst = w.encoder.Writer(w.encoded)
+	}
+	w.printer.Reset(dst)
+
+	for i := range w.nodes {
+		w.nodes[i] = node{}
+	}
+	w.current = -1
+	w.floor = -1
+	w.child = nil
+	w.last = Event{}
+	for i := range w.attrs {
+		w.attrs[i] = Attr{}
+	}
+	w.attrs = w.attrs[:0]
+	w.attrIndented = false
+	w.err, w.failed = nil, nil
+	w.stats = Stats{}
+	w.leafStart = 0
+	w.ctx, w.done = nil, nil
+
+	if r, ok := w.Indenter.(indentResetter); ok {
+		r.Reset()
+	}
+}
+
 // Depth returns the number of opened Startable nodes on the stack.
 func (w *Writer) Depth() int {
 	return w.current
@@ -119,7 +273,12 @@ func (w *Writer) Depth() int {
 // is so that you can write raw strings inside elements that are open
 // but not opened. See StateOpen and StateOpened for more details on
 // this distinction.
-func (w *Writer) Next() error {
+func (w *Writer) Next() (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "Next", nil)
+
 	if w.current >= 0 {
 		w.nodes[w.current].children++
 		if w.nodes[w.current].state == StateOpen {
@@ -134,7 +293,12 @@ func (w *Writer) Next() error {
 // Block is a convenience function that takes a parent node and a list of
 // direct children. The parent node is passed to Writer.Start(), the
 // children are passed to Write(), then the parent passed to End().
-func (w *Writer) Block(start Startable, nodes ...Writable) error {
+func (w *Writer) Block(start Startable, nodes ...Writable) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "Block", func() string { return debugArg(start) + ": " + debugNodes(nodes) })
+
 	if err := w.Start(start); err != nil {
 		return err
 	}
@@ -150,7 +314,12 @@ func (w *Writer) Block(start Startable, nodes ...Writable) error {
 }
 
 // Write writes writable nodes.
-func (w *Writer) Write(nodes ...Writable) error {
+func (w *Writer) Write(nodes ...Writable) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "Write", func() string { return debugNodes(nodes) })
+
 	for _, node := range nodes {
 		if err := node.write(w); err != nil {
 			return err
@@ -160,7 +329,12 @@ func (w *Writer) Write(nodes ...Writable) error {
 }
 
 // Start starts a startable node.
-func (w *Writer) Start(nodes ...Startable) error {
+func (w *Writer) Start(nodes ...Startable) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "Start", func() string { return debugStartables(nodes) })
+
 	for _, node := range nodes {
 		if err := node.start(w); err != nil {
 			return err
@@ -171,33 +345,87 @@ func (w *Writer) Start(nodes ...Startable) error {
 
 // Flush ensures the output buffer accumuated inside the Writer
 // is fully written to the underlying io.Writer.
-func (w *Writer) Flush() error {
+func (w *Writer) Flush() (err error) {
+	if w.err != nil {
+		w.printer.Flush()
+		return w.failed
+	}
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "Flush", nil)
+
+	if err := w.checkContext(); err != nil {
+		return err
+	}
 	return w.printer.Flush()
 }
 
 // {{{ start methods for startables
 
 // StartDoc pushes an XML document node onto the writer's stack.
-func (w *Writer) StartDoc(doc Doc) error { return doc.start(w) }
+func (w *Writer) StartDoc(doc Doc) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "StartDoc", func() string { return debugArg(doc) })
+
+	return doc.start(w)
+}
 
 // StartComment pushes an XML comment node onto the writer's stack.
 // WriteCommentContent can be used to write contents.
-func (w *Writer) StartComment(comment Comment) error { return comment.start(w) }
+func (w *Writer) StartComment(comment Comment) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "StartComment", func() string { return debugArg(comment) })
+
+	return comment.start(w)
+}
 
 // StartCData pushes an XML CData node onto the writer's stack.
 // WriteCDataContent can be used to write contents.
-func (w *Writer) StartCData(cdata CData) error { return cdata.start(w) }
+func (w *Writer) StartCData(cdata CData) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "StartCData", func() string { return debugArg(cdata) })
+
+	return cdata.start(w)
+}
 
 // StartDTD pushes a Document Type Declaration node onto the writer's
 // stack.
-func (w *Writer) StartDTD(dtd DTD) error { return dtd.start(w) }
+func (w *Writer) StartDTD(dtd DTD) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "StartDTD", func() string { return debugArg(dtd) })
+
+	return dtd.start(w)
+}
 
 // StartDTDAttList pushes a Document Type Declaration node onto the writer's
 // stack.
-func (w *Writer) StartDTDAttList(al DTDAttList) error { return al.start(w) }
+func (w *Writer) StartDTDAttList(al DTDAttList) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "StartDTDAttList", func() string { return debugArg(al) })
+
+	return al.start(w)
+}
 
 // StartElem pushes an XML element node onto the writer's stack.
-func (w *Writer) StartElem(elem Elem) error { return elem.start(w) }
+func (w *Writer) StartElem(elem Elem) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "StartElem", func() string { return debugArg(elem) })
+
+	return elem.start(w)
+}
 
 // }}}
 
@@ -205,11 +433,25 @@ func (w *Writer) StartElem(elem Elem) error { return elem.start(w) }
 
 // WriteCData writes a complete XML CData section. It can be written inside an
 // Elem or as a top-level node.
-func (w *Writer) WriteCData(cdata CData) (err error) { return cdata.write(w) }
+func (w *Writer) WriteCData(cdata CData) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteCData", func() string { return debugArg(cdata) })
+
+	return cdata.write(w)
+}
 
 // WriteComment writes a complete XML Comment section. It can be written inside an
 // Elem, a DTD, a Doc, or as a top-level node.
-func (w *Writer) WriteComment(comment Comment) (err error) { return comment.write(w) }
+func (w *Writer) WriteComment(comment Comment) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteComment", func() string { return debugArg(comment) })
+
+	return comment.write(w)
+}
 
 // WriteElem writes a complete XML Element. It can be written inside an
 // Elem, a Doc, or as a top-level node.
@@ -224,54 +466,136 @@ func (w *Writer) WriteComment(comment Comment) (err error) { return comment.writ
 //		Content: []Writable{Elem{Name: "inner"}},
 //	}
 //
-func (w *Writer) WriteElem(elem Elem) (err error) { return elem.write(w) }
+func (w *Writer) WriteElem(elem Elem) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteElem", func() string { return debugArg(elem) })
+
+	return elem.write(w)
+}
 
 // }}}
 
 // {{{ write methods for non-startable writables
 
 // WriteCDataContent writes text inside an already-started XML CData node.
-func (w *Writer) WriteCDataContent(cdata string) error { return CDataContent(cdata).write(w) }
+func (w *Writer) WriteCDataContent(cdata string) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteCDataContent", func() string { return debugArg(cdata) })
+
+	return CDataContent(cdata).write(w)
+}
 
 // WriteCommentContent writes text inside an already-started XML Comment node.
-func (w *Writer) WriteCommentContent(comment string) error { return CommentContent(comment).write(w) }
+func (w *Writer) WriteCommentContent(comment string) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteCommentContent", func() string { return debugArg(comment) })
+
+	return CommentContent(comment).write(w)
+}
 
 // WriteDTDEntity writes a DTD Entity definition to the output. It can be
 // written inside a DTD or as a top-level node.
-func (w *Writer) WriteDTDEntity(entity DTDEntity) error { return entity.write(w) }
+func (w *Writer) WriteDTDEntity(entity DTDEntity) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteDTDEntity", func() string { return debugArg(entity) })
+
+	return entity.write(w)
+}
 
 // WriteDTDElem writes a DTD Element definition to the output. It can be
 // written inside a DTD or as a top-level node.
-func (w *Writer) WriteDTDElem(el DTDElem) error { return el.write(w) }
+func (w *Writer) WriteDTDElem(el DTDElem) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteDTDElem", func() string { return debugArg(el) })
+
+	return el.write(w)
+}
 
 // WriteDTDAttr writes a DTD Attribute definition to the output. It can be
 // written inside a DTDAttList or as a top-level node.
-func (w *Writer) WriteDTDAttr(attr DTDAttr) (err error) { return attr.write(w) }
+func (w *Writer) WriteDTDAttr(attr DTDAttr) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteDTDAttr", func() string { return debugArg(attr) })
+
+	return attr.write(w)
+}
 
 // WriteDTDAttList writes a DTD Attribute List to the output. It can be written inside
 // a DTD or as a top-level node.
-func (w *Writer) WriteDTDAttList(attlist DTDAttList) (err error) { return attlist.write(w) }
+func (w *Writer) WriteDTDAttList(attlist DTDAttList) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteDTDAttList", func() string { return debugArg(attlist) })
+
+	return attlist.write(w)
+}
 
 // WriteNotation writes an XML notation to the output. It can be written inside
 // a DTD or as a top-level node.
-func (w *Writer) WriteNotation(n Notation) (err error) { return n.write(w) }
+func (w *Writer) WriteNotation(n Notation) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteNotation", func() string { return debugArg(n) })
+
+	return n.write(w)
+}
 
 // WritePI writes an XML processing instruction to the output. It can be
 // written inside a Doc, an Elem or as a top-level node.
-func (w *Writer) WritePI(pi PI) error { return pi.write(w) }
+func (w *Writer) WritePI(pi PI) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WritePI", func() string { return debugArg(pi) })
+
+	return pi.write(w)
+}
 
 // WriteText writes an XML text node to the output. It will be appropriately
 // escaped. It can be written inside an Elem or as a top-level node.
-func (w *Writer) WriteText(text string) (err error) { return Text(text).write(w) }
+func (w *Writer) WriteText(text string) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteText", func() string { return debugArg(text) })
+
+	return Text(text).write(w)
+}
 
 // WriteRaw writes a raw string to the output. This can be any string
 // whatsoever - it does not have to be valid XML and will be written exactly as
 // it is declared. Raw nodes can be written at any stage of the writing
 // process.
-func (w *Writer) WriteRaw(raw string) error { return Raw(raw).write(w) }
+func (w *Writer) WriteRaw(raw string) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteRaw", func() string { return debugArg(raw) })
+
+	return Raw(raw).write(w)
+}
 
 // WriteAttr writes one or more XML element attributes to the output.
 func (w *Writer) WriteAttr(attrs ...Attr) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteAttr", func() string { return debugAttrs(attrs) })
+
 	for _, a := range attrs {
 		if err := a.write(w); err != nil {
 			return err
@@ -282,12 +606,164 @@ func (w *Writer) WriteAttr(attrs ...Attr) (err error) {
 
 // }}}
 
+// {{{ byte slice write methods
+
+// WriteTextBytes is WriteText for a byte slice. text is escaped as it is
+// written, without being copied unless an Indenter is in use.
+func (w *Writer) WriteTextBytes(text []byte) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteTextBytes", func() string { return debugArg(string(text)) })
+
+	return w.writeTextBytes(text, true)
+}
+
+// WriteAttrBytes writes an attribute whose value is a byte slice. value is
+// escaped as it is written, without being copied unless it is needed as a
+// string because an Indenter or Listener is in use, or attributes are held
+// back by AttrOrder.
+func (w *Writer) WriteAttrBytes(name string, value []byte) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteAttrBytes", func() string { return debugArg(name) + ", " + debugArg(string(value)) })
+
+	return w.writeAttr(Attr{Name: name}, value, true)
+}
+
+// WriteCDataContentBytes is WriteCDataContent for a byte slice.
+func (w *Writer) WriteCDataContentBytes(cdata []byte) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteCDataContentBytes", func() string { return debugArg(string(cdata)) })
+
+	return w.writeCDataContentBytes(cdata)
+}
+
+// WriteCommentContentBytes is WriteCommentContent for a byte slice.
+func (w *Writer) WriteCommentContentBytes(comment []byte) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteCommentContentBytes", func() string { return debugArg(string(comment)) })
+
+	return w.writeCommentContentBytes(comment)
+}
+
+// WriteRawBytes is WriteRaw for a byte slice.
+func (w *Writer) WriteRawBytes(raw []byte) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteRawBytes", func() string { return debugArg(string(raw)) })
+
+	return w.writeRawBytes(raw)
+}
+
+// }}}
+
+// {{{ typed value write methods
+
+// Typed value writers format the value straight into the output without
+// allocating, unless the value is needed as a string because an Indenter or
+// Listener is in use, or attributes are held back by AttrOrder. Formatted
+// numbers never need escaping.
+
+// WriteAttrInt writes an attribute whose value is an int64.
+func (w *Writer) WriteAttrInt(name string, v int64) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteAttrInt", func() string { return debugArg(name) + ", " + debugArg(v) })
+
+	return w.writeAttr(Attr{Name: name}, strconv.AppendInt(w.scratch[:0], v, 10), false)
+}
+
+// WriteAttrUint writes an attribute whose value is a uint64.
+func (w *Writer) WriteAttrUint(name string, v uint64) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteAttrUint", func() string { return debugArg(name) + ", " + debugArg(v) })
+
+	return w.writeAttr(Attr{Name: name}, strconv.AppendUint(w.scratch[:0], v, 10), false)
+}
+
+// WriteAttrFloat writes an attribute whose value is a float64, formatted like Attr.Float64.
+func (w *Writer) WriteAttrFloat(name string, v float64) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteAttrFloat", func() string { return debugArg(name) + ", " + debugArg(v) })
+
+	return w.writeAttr(Attr{Name: name}, strconv.AppendFloat(w.scratch[:0], v, 'g', -1, 64), false)
+}
+
+// WriteAttrBool writes an attribute whose value is a bool.
+func (w *Writer) WriteAttrBool(name string, v bool) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteAttrBool", func() string { return debugArg(name) + ", " + debugArg(v) })
+
+	return w.writeAttr(Attr{Name: name}, strconv.AppendBool(w.scratch[:0], v), false)
+}
+
+// WriteTextInt writes a text node containing an int64.
+func (w *Writer) WriteTextInt(v int64) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteTextInt", func() string { return debugArg(v) })
+
+	return w.writeTextBytes(strconv.AppendInt(w.scratch[:0], v, 10), false)
+}
+
+// WriteTextUint writes a text node containing a uint64.
+func (w *Writer) WriteTextUint(v uint64) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteTextUint", func() string { return debugArg(v) })
+
+	return w.writeTextBytes(strconv.AppendUint(w.scratch[:0], v, 10), false)
+}
+
+// WriteTextFloat writes a text node containing a float64, formatted like Attr.Float64.
+func (w *Writer) WriteTextFloat(v float64) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteTextFloat", func() string { return debugArg(v) })
+
+	return w.writeTextBytes(strconv.AppendFloat(w.scratch[:0], v, 'g', -1, 64), false)
+}
+
+// WriteTextBool writes a text node containing a bool.
+func (w *Writer) WriteTextBool(v bool) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "WriteTextBool", func() string { return debugArg(v) })
+
+	return w.writeTextBytes(strconv.AppendBool(w.scratch[:0], v), false)
+}
+
+// }}}
+
 // {{{ end methods
 
 // EndDoc ends the current xmlwriter.Doc{} and any node in between. This is not
 // the same as calling End(DocNode), which will only end a Doc{} if it is the
 // current node on the stack.
 func (w *Writer) EndDoc() (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndDoc", nil)
+
 	for {
 		if w.current <= 0 {
 			break
@@ -301,29 +777,69 @@ func (w *Writer) EndDoc() (err error) {
 
 // EndCData pops a CData node from the writer's stack, or returns an error
 // if the current node is not a CData.
-func (w *Writer) EndCData() (err error) { return w.End(CDataNode) }
+func (w *Writer) EndCData() (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndCData", nil)
+
+	return w.End(CDataNode)
+}
 
 // EndComment pops a Comment node from the writer's stack, or returns an error
 // if the current node is not a Comment.
-func (w *Writer) EndComment() (err error) { return w.End(CommentNode) }
+func (w *Writer) EndComment() (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndComment", nil)
+
+	return w.End(CommentNode)
+}
 
 // EndDTD pops a DTD node from the writer's stack, or returns an error
 // if the current node is not a DTD.
-func (w *Writer) EndDTD() (err error) { return w.End(DTDNode) }
+func (w *Writer) EndDTD() (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndDTD", nil)
+
+	return w.End(DTDNode)
+}
 
 // EndDTDAttList pops a DTDAttList node from the writer's stack, or returns an
 // error if the current node is not a DTDAttList.
-func (w *Writer) EndDTDAttList() (err error) { return w.End(DTDAttListNode) }
+func (w *Writer) EndDTDAttList() (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndDTDAttList", nil)
+
+	return w.End(DTDAttListNode)
+}
 
 // EndElem pops an Elem node from the writer's stack, or returns an error if
 // the current node is not an Elem. If the Elem has had no children written, it
 // will be closed using the short close style: "<tag/>"
-func (w *Writer) EndElem(name ...string) error { return w.End(ElemNode, name...) }
+func (w *Writer) EndElem(name ...string) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndElem", func() string { return debugNames(name) })
+
+	return w.End(ElemNode, name...)
+}
 
 // EndElemFull pops an Elem node from the writer's stack, or returns an error if
 // the current node is not an Elem. It will always be closed using the full
 // element close style even if it contains no children: "<tag></tag>".
-func (w *Writer) EndElemFull(name ...string) error {
+func (w *Writer) EndElemFull(name ...string) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndElemFull", func() string { return debugNames(name) })
+
 	if w.current >= 0 {
 		w.nodes[w.current].elem.Full = true
 	}
@@ -331,8 +847,13 @@ func (w *Writer) EndElemFull(name ...string) error {
 }
 
 // EndAny ends the current node, regardless of what kind of node it is.
-func (w *Writer) EndAny() error {
-	if w.current < 0 {
+func (w *Writer) EndAny() (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndAny", nil)
+
+	if w.current <= w.floor {
 		return fmt.Errorf("xmlwriter: could not pop node")
 	}
 	return w.pop()
@@ -345,7 +866,12 @@ func (w *Writer) EndAny() error {
 // field, the second is compared to the node's Name field.
 // This form works with the following node types: ElemNode, DTDNode,
 // DTDAttListNode.
-func (w *Writer) End(kind NodeKind, name ...string) error {
+func (w *Writer) End(kind NodeKind, name ...string) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "End", func() string { return debugEnd(kind, name) })
+
 	if w.current < 0 {
 		return fmt.Errorf("xmlwriter: could not pop node")
 	}
@@ -385,9 +911,14 @@ func (w *Writer) End(kind NodeKind, name ...string) error {
 }
 
 // EndAll ends every node on the stack
-func (w *Writer) EndAll() error {
+func (w *Writer) EndAll() (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndAll", nil)
+
 	for {
-		if w.current < 0 {
+		if w.current <= w.floor {
 			break
 		}
 		if err := w.pop(); err != nil {
@@ -415,7 +946,12 @@ func (w *Writer) EndAll() error {
 //		w.Start(Elem{Name: "bar"})
 //	}
 //
-func (w *Writer) EndToDepth(depth int, kind NodeKind, name ...string) error {
+func (w *Writer) EndToDepth(depth int, kind NodeKind, name ...string) (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndToDepth", func() string { return debugArg(depth) + ", " + debugEnd(kind, name) })
+
 	limit := depth + 1
 	for {
 		if w.current <= limit {
@@ -429,7 +965,12 @@ func (w *Writer) EndToDepth(depth int, kind NodeKind, name ...string) error {
 }
 
 // EndAllFlush ends every node on the stack and calls Flush()
-func (w *Writer) EndAllFlush() error {
+func (w *Writer) EndAllFlush() (err error) {
+	if err := w.enter(); err != nil {
+		return err
+	}
+	defer w.exit(&err, "EndAllFlush", nil)
+
 	if err := w.EndAll(); err != nil {
 		return err
 	}
@@ -441,15 +982,24 @@ func (w *Writer) EndAllFlush() error {
 // {{{ Internal functions
 
 func (w *Writer) writeIndent(next Event) error {
+	if w.child != nil && w.child.first.Node == NoNode {
+		w.child.first = next
+	}
 	return w.Indenter.Indent(w, w.last, next)
 }
 
 func (w *Writer) pushBegin(kind NodeKind, parents nodeFlag) error {
+	if err := w.checkContext(); err != nil {
+		return err
+	}
 	if w.Enforce {
 		if err := w.checkParent(parents); err != nil {
 			return err
 		}
 	}
+	if w.limits.MaxDepth > 0 && w.current+2 > w.limits.MaxDepth {
+		return &LimitError{Limit: "MaxDepth", Max: int64(w.limits.MaxDepth)}
+	}
 	if len(w.nodes) <= w.current+1 {
 		w.nodes = append(w.nodes, node{})
 	}
@@ -458,6 +1008,9 @@ func (w *Writer) pushBegin(kind NodeKind, parents nodeFlag) error {
 
 func (w *Writer) pushEnd() error {
 	w.current++
+	if w.current > w.stats.MaxDepth {
+		w.stats.MaxDepth = w.current
+	}
 	return w.nodes[w.current].open(w)
 }
 
@@ -484,22 +1037,40 @@ func (w *Writer) checkParent(nodeFlags nodeFlag) error {
 }
 
 func (w *Writer) writeBeginNext(kind NodeKind) error {
-	w.Next()
+	if err := w.Next(); err != nil {
+		return err
+	}
 	return w.writeBeginCur(kind)
 }
 
 func (w *Writer) writeBeginCur(kind NodeKind) error {
+	if err := w.checkContext(); err != nil {
+		return err
+	}
 	if w.Indenter != nil {
-		if err := w.writeIndent(Event{StateOpen, kind, 0}); err != nil {
+		if err := w.writeIndent(w.leafEvent(StateOpen, kind)); err != nil {
 			return err
 		}
-		w.last = Event{StateEnded, kind, 0}
+		w.last = w.leafEvent(StateEnded, kind)
+	}
+	if len(w.listeners) > 0 {
+		w.leafStart = w.printer.Offset()
+		return w.notify(w.leafEvent(StateOpen, kind))
 	}
 	return nil
 }
 
+// leafEvent returns the Event raised for a node which is never the Writer's
+// current node, i.e. one which can be written but not started.
+func (w *Writer) leafEvent(state NodeState, kind NodeKind) Event {
+	return Event{State: state, Node: kind, Depth: w.current + 1}
+}
+
 func (w *Writer) pop(kinds ...NodeKind) error {
-	if w.current < 0 {
+	if err := w.checkContext(); err != nil {
+		return err
+	}
+	if w.current <= w.floor {
 		return fmt.Errorf("xmlwriter: could not pop node")
 	}
 
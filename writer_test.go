@@ -9,6 +9,8 @@ import (
 	"testing"
 
 	tt "github.com/shabbyrobe/xmlwriter/testtool"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
 )
 
 func TestDoc(t *testing.T) {
@@ -257,6 +259,63 @@ func TestWriteBadAttr(t *testing.T) {
 		w.Write(Attr{Name: "yep"}))
 }
 
+func TestWriteAttrOrderName(t *testing.T) {
+	b, w := open(WithAttrOrder(AttrOrderName))
+	(&ErrCollector{}).Must(
+		w.Start(Elem{Name: "elem", Prefix: "p", URI: "urn:p", Attrs: []Attr{
+			{Name: "zed", Value: "1"},
+			{Name: "b", Prefix: "p", Value: "2"},
+		}}),
+		w.WriteAttr(
+			Attr{Name: "a", Value: "3"},
+			Attr{Name: "q", Prefix: "xmlns", Value: "urn:q"},
+			Attr{Name: "xmlns", Value: "urn:default"},
+			Attr{Name: "c", Prefix: "r", URI: "urn:r", Value: "4"},
+		),
+	)
+	tt.Equals(t, "<p:elem", str(b, w))
+
+	(&ErrCollector{}).Must(w.Write(Elem{Name: "child", Attrs: []Attr{{Name: "y"}, {Name: "x"}}}), w.EndAll())
+	tt.Equals(t, `<p:elem xmlns="urn:default" xmlns:p="urn:p" xmlns:q="urn:q" xmlns:r="urn:r" `+
+		`a="3" p:b="2" r:c="4" zed="1"><child x="" y=""/></p:elem>`, str(b, w))
+}
+
+func TestWriteAttrOrderNamespace(t *testing.T) {
+	b, w := open(WithAttrOrder(AttrOrderNamespace))
+	(&ErrCollector{}).Must(
+		w.Start(Elem{Name: "elem", Prefix: "z", URI: "urn:a"}),
+		w.WriteAttr(
+			Attr{Name: "b", Prefix: "a", URI: "urn:z", Value: "1"},
+			Attr{Name: "b", Prefix: "z", Value: "2"},
+			Attr{Name: "a", Prefix: "z", Value: "3"},
+			Attr{Name: "c", Value: "4"},
+		),
+		w.EndAll(),
+	)
+	tt.Equals(t, `<z:elem xmlns:a="urn:z" xmlns:z="urn:a" c="4" z:a="3" z:b="2" a:b="1"/>`, str(b, w))
+}
+
+func TestWriteAttrOrderMany(t *testing.T) {
+	b, w := open(WithAttrOrder(AttrOrderName))
+	must(w.Start(Elem{Name: "elem"}))
+	for i := smallAttrSort * 2; i > 0; i-- {
+		must(w.WriteAttr(Attr{Name: fmt.Sprintf("a%03d", i)}))
+	}
+	must(w.EndAll())
+
+	out := str(b, w)
+	for i := 1; i < smallAttrSort*2; i++ {
+		cur, next := fmt.Sprintf("a%03d", i), fmt.Sprintf("a%03d", i+1)
+		tt.Assert(t, strings.Index(out, cur) < strings.Index(out, next), out)
+	}
+}
+
+func TestWriteAttrOrderBadName(t *testing.T) {
+	_, w := open(WithAttrOrder(AttrOrderName))
+	must(w.Start(Elem{Name: "elem"}))
+	tt.Pattern(t, `invalid name`, w.WriteAttr(Attr{Name: "$"}).Error())
+}
+
 func TestNest(t *testing.T) {
 	ec := &ErrCollector{}
 	b, w := open()
@@ -419,28 +478,29 @@ func TestLast(t *testing.T) {
 		(&ErrCollector{}).Must(w.Write(n))
 		return w.last
 	}
-	tt.Equals(t, Event{StateOpen, ElemNode, 0}, startLast(Elem{Name: "foo"}))
-	tt.Equals(t, Event{StateEnded, ElemNode, 0}, writeLast(Elem{Name: "foo"}))
-	tt.Equals(t, Event{StateEnded, AttrNode, 0}, writeLast(Attr{Name: "foo", Value: "bar"}))
-	tt.Equals(t, Event{StateEnded, AttrNode, 0}, startLast(Elem{Name: "foo", Attrs: []Attr{{Name: "foo", Value: "bar"}}}))
-	tt.Equals(t, Event{StateOpen, DocNode, 0}, startLast(Doc{}))
-	tt.Equals(t, Event{StateOpen, DTDNode, 0}, startLast(DTD{Name: "pants"}))
-
-	tt.Equals(t, Event{StateOpen, CDataNode, 0}, startLast(CData{}))
-	tt.Equals(t, Event{StateOpen, CommentNode, 0}, startLast(Comment{}))
-	tt.Equals(t, Event{StateOpen, DTDNode, 0}, startLast(DTD{Name: "yep"}))
-	tt.Equals(t, Event{StateOpen, DTDAttListNode, 0}, startLast(DTDAttList{Name: "yep"}))
-	tt.Equals(t, Event{StateOpen, DocNode, 0}, startLast(Doc{}))
-
-	tt.Equals(t, Event{StateEnded, NotationNode, 0}, writeLast(Notation{Name: "yep", SystemID: "sys"}))
-	tt.Equals(t, Event{StateEnded, PINode, 0}, writeLast(PI{}))
-	tt.Equals(t, Event{StateEnded, RawNode, 0}, writeLast(Raw("foo")))
-	tt.Equals(t, Event{StateEnded, TextNode, 0}, writeLast(Text("foo")))
-	tt.Equals(t, Event{StateEnded, CDataContentNode, 0}, writeLast(CDataContent("foo")))
-	tt.Equals(t, Event{StateEnded, CommentContentNode, 0}, writeLast(CommentContent("foo")))
-	tt.Equals(t, Event{StateEnded, DTDAttrNode, 0}, writeLast(DTDAttr{Name: "yep", Type: "CDATA"}))
-	tt.Equals(t, Event{StateEnded, DTDElemNode, 0}, writeLast(DTDElem{Name: "yep", Decl: "yep"}))
-	tt.Equals(t, Event{StateEnded, DTDEntityNode, 0}, writeLast(DTDEntity{Name: "yep", Content: "yep"}))
+	tt.Equals(t, Event{State: StateOpen, Node: ElemNode, Name: "foo"}, startLast(Elem{Name: "foo"}))
+	tt.Equals(t, Event{State: StateOpen, Node: ElemNode, Prefix: "p", Name: "foo", URI: "urn:p"}, startLast(Elem{Prefix: "p", URI: "urn:p", Name: "foo"}))
+	tt.Equals(t, Event{State: StateEnded, Node: ElemNode, Name: "foo"}, writeLast(Elem{Name: "foo"}))
+	tt.Equals(t, Event{State: StateEnded, Node: AttrNode, Name: "foo", Value: "bar"}, writeLast(Attr{Name: "foo", Value: "bar"}))
+	tt.Equals(t, Event{State: StateEnded, Node: AttrNode, Name: "foo", Value: "bar", Depth: 1}, startLast(Elem{Name: "foo", Attrs: []Attr{{Name: "foo", Value: "bar"}}}))
+	tt.Equals(t, Event{State: StateOpen, Node: DocNode}, startLast(Doc{}))
+	tt.Equals(t, Event{State: StateOpen, Node: DTDNode}, startLast(DTD{Name: "pants"}))
+
+	tt.Equals(t, Event{State: StateOpen, Node: CDataNode}, startLast(CData{}))
+	tt.Equals(t, Event{State: StateOpen, Node: CommentNode}, startLast(Comment{}))
+	tt.Equals(t, Event{State: StateOpen, Node: DTDNode}, startLast(DTD{Name: "yep"}))
+	tt.Equals(t, Event{State: StateOpen, Node: DTDAttListNode}, startLast(DTDAttList{Name: "yep"}))
+	tt.Equals(t, Event{State: StateOpen, Node: DocNode}, startLast(Doc{}))
+
+	tt.Equals(t, Event{State: StateEnded, Node: NotationNode}, writeLast(Notation{Name: "yep", SystemID: "sys"}))
+	tt.Equals(t, Event{State: StateEnded, Node: PINode}, writeLast(PI{}))
+	tt.Equals(t, Event{State: StateEnded, Node: RawNode}, writeLast(Raw("foo")))
+	tt.Equals(t, Event{State: StateEnded, Node: TextNode}, writeLast(Text("foo")))
+	tt.Equals(t, Event{State: StateEnded, Node: CDataContentNode}, writeLast(CDataContent("foo")))
+	tt.Equals(t, Event{State: StateEnded, Node: CommentContentNode}, writeLast(CommentContent("foo")))
+	tt.Equals(t, Event{State: StateEnded, Node: DTDAttrNode}, writeLast(DTDAttr{Name: "yep", Type: "CDATA"}))
+	tt.Equals(t, Event{State: StateEnded, Node: DTDElemNode}, writeLast(DTDElem{Name: "yep", Decl: "yep"}))
+	tt.Equals(t, Event{State: StateEnded, Node: DTDEntityNode}, writeLast(DTDEntity{Name: "yep", Content: "yep"}))
 }
 
 func TestInvalidParent(t *testing.T) {
@@ -788,3 +848,228 @@ func TestAllocs(t *testing.T) {
 	tt.Equals(t, uint64(0), after-before)
 	w.Flush()
 }
+
+func TestAllocsAttrOrder(t *testing.T) {
+	ec := &ErrCollector{}
+	w := Open(ioutil.Discard, WithAttrOrder(AttrOrderNamespace))
+
+	_ = allocs()
+
+	before := allocs()
+	ec.Must(w.StartElem(Elem{Name: "foo"}))
+	ec.Must(w.WriteAttr(Attr{Name: "c"}, Attr{Name: "b"}, Attr{Name: "a"}))
+	ec.Must(w.StartElem(Elem{Name: "bar"}))
+	ec.Must(w.WriteAttr(Attr{Name: "b"}, Attr{Name: "a"}))
+	ec.Must(w.EndAll())
+	after := allocs()
+	tt.Equals(t, uint64(0), after-before)
+	w.Flush()
+}
+
+func TestReset(t *testing.T) {
+	b, w := open(WithIndent(), WithLimits(Limits{MaxDepth: 3}))
+	ec := &ErrCollector{}
+	ec.Must(w.Start(Doc{}, Elem{Name: "foo", Prefix: "a", URI: "urn:a"}, Elem{Name: "bar"}))
+	tt.Assert(t, w.Write(Text("x"), Elem{Name: "baz"}) != nil)
+	tt.Assert(t, w.Err() != nil)
+
+	b2 := &bytes.Buffer{}
+	w.Reset(b2)
+	tt.OK(t, w.Err())
+	tt.Equals(t, -1, w.Depth())
+	tt.Equals(t, Stats{}, w.Stats())
+
+	// the namespace declared on <a:foo> is gone, so it's declared again:
+	ec.Must(w.Start(Elem{Name: "foo", Prefix: "a", URI: "urn:a"}), w.Write(Elem{Name: "bar"}), w.EndAll())
+	tt.Equals(t, "<a:foo xmlns:a=\"urn:a\">\n <bar/>\n</a:foo>", str(b2, w))
+	tt.Equals(t, "", b.String())
+
+	// limits are kept:
+	ec.Must(w.Start(Elem{Name: "a"}, Elem{Name: "b"}, Elem{Name: "c"}))
+	tt.Assert(t, w.Start(Elem{Name: "d"}) != nil)
+}
+
+func TestResetEncoding(t *testing.T) {
+	enc := charmap.ISO8859_1.NewEncoder()
+	w := OpenEncoding(ioutil.Discard, "ISO-8859-1", enc)
+	tt.OK(t, w.Write(Elem{Name: "a"}, Text("é")))
+
+	b := &bytes.Buffer{}
+	w.Reset(b)
+	tt.OK(t, w.Write(Elem{Name: "b"}, Text("é")))
+	tt.OK(t, w.Flush())
+	tt.Equals(t, []byte{'<', 'b', '/', '>', 0xE9}, b.Bytes())
+	tt.Equals(t, int64(5), w.Stats().EncodedBytes)
+}
+
+func TestResetEncodingBOM(t *testing.T) {
+	enc := unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewEncoder()
+	write := func(w *Writer) {
+		ec := &ErrCollector{}
+		ec.Must(w.Start(Doc{}), w.Write(Elem{Name: "a"}), w.EndAllFlush())
+	}
+
+	b1 := &bytes.Buffer{}
+	w := OpenEncoding(b1, "UTF-16", enc)
+	write(w)
+	tt.Equals(t, []byte{0xFE, 0xFF, 0, '<'}, b1.Bytes()[:4])
+
+	b2 := &bytes.Buffer{}
+	w.Reset(b2)
+	write(w)
+	tt.Equals(t, b1.Bytes(), b2.Bytes())
+}
+
+func TestResetAllocs(t *testing.T) {
+	ec := &ErrCollector{}
+	w := Open(ioutil.Discard, WithIndent())
+	ec.Must(w.Start(Elem{Name: "foo"}, Elem{Name: "bar"}), w.EndAllFlush())
+
+	_ = allocs()
+
+	before := allocs()
+	w.Reset(ioutil.Discard)
+	ec.Must(w.StartElem(Elem{Name: "foo"}), w.StartElem(Elem{Name: "bar"}), w.EndAllFlush())
+	after := allocs()
+	tt.Equals(t, uint64(0), after-before)
+}
+
+func TestWriteTypedValues(t *testing.T) {
+	ec := &ErrCollector{}
+	b, w := open()
+	ec.Must(
+		w.StartElem(Elem{Name: "foo"}),
+		w.WriteAttrInt("int", -10),
+		w.WriteAttrUint("uint", 18446744073709551615),
+		w.WriteAttrFloat("float", 234.56),
+		w.WriteAttrBool("bool", true),
+		w.WriteTextInt(-1),
+		w.WriteText(" "),
+		w.WriteTextUint(2),
+		w.WriteText(" "),
+		w.WriteTextFloat(1e21),
+		w.WriteText(" "),
+		w.WriteTextBool(false),
+		w.EndAll(),
+	)
+	tt.Equals(t, `<foo int="-10" uint="18446744073709551615" float="234.56" bool="true">`+
+		`-1 2 1e+21 false</foo>`, str(b, w))
+}
+
+func TestWriteTypedValuesEvents(t *testing.T) {
+	for _, tc := range []struct {
+		opt    Option
+		out    string
+		values []string
+	}{
+		{WithIndent(), `<foo b="2" a="1.5"/>`, []string{"b=2", "a=1.5"}},
+		{WithAttrOrder(AttrOrderName), `<foo a="1.5" b="2"/>`, []string{"a=1.5", "b=2"}},
+	} {
+		var values []string
+		b, w := open(tc.opt, WithListener(ListenerFunc(func(w *Writer, ev Event) error {
+			if ev.Node == AttrNode && ev.State == StateEnded {
+				values = append(values, ev.Name+"="+ev.Value)
+			}
+			return nil
+		})))
+		ec := &ErrCollector{}
+		ec.Must(
+			w.StartElem(Elem{Name: "foo"}),
+			w.WriteAttrInt("b", 2),
+			w.WriteAttrFloat("a", 1.5),
+			w.EndAll(),
+		)
+		tt.Equals(t, tc.out, str(b, w))
+		tt.Equals(t, tc.values, values)
+	}
+}
+
+func TestWriteTypedValuesBadName(t *testing.T) {
+	_, w := open()
+	tt.OK(t, w.StartElem(Elem{Name: "foo"}))
+	tt.Pattern(t, `invalid`, w.WriteAttrInt("a b", 1).Error())
+}
+
+func TestAllocsTypedValues(t *testing.T) {
+	ec := &ErrCollector{}
+	w := Open(ioutil.Discard)
+
+	_ = allocs()
+
+	before := allocs()
+	ec.Must(w.StartElem(Elem{Name: "foo"}))
+	for i := 0; i < 100; i++ {
+		ec.Must(
+			w.StartElem(Elem{Name: "v"}),
+			w.WriteAttrInt("i", int64(i)),
+			w.WriteAttrFloat("f", float64(i)/3),
+			w.WriteAttrBool("b", i%2 == 0),
+			w.WriteTextFloat(float64(i)*1.5),
+			w.EndElem(),
+		)
+	}
+	ec.Must(w.EndAll())
+	after := allocs()
+	tt.Equals(t, uint64(0), after-before)
+	w.Flush()
+}
+
+func TestWriteBytes(t *testing.T) {
+	ec := &ErrCollector{}
+	b, w := open()
+	ec.Must(
+		w.StartElem(Elem{Name: "foo"}),
+		w.WriteAttrBytes("a", []byte(`"1" & <2>`)),
+		w.WriteTextBytes([]byte("a < b & c")),
+		w.StartComment(Comment{}),
+		w.WriteCommentContentBytes([]byte("yep")),
+		w.EndComment(),
+		w.StartCData(CData{}),
+		w.WriteCDataContentBytes([]byte("<&>")),
+		w.EndCData(),
+		w.WriteRawBytes([]byte("<raw/>")),
+		w.EndAll(),
+	)
+	tt.Equals(t, `<foo a="&#34;1&#34; &amp; &lt;2&gt;">a &lt; b &amp; c<!--yep--><![CDATA[<&>]]><raw/></foo>`, str(b, w))
+}
+
+func TestWriteBytesInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		start Startable
+		write func(w *Writer) error
+		err   string
+	}{
+		{Comment{}, func(w *Writer) error { return w.WriteCommentContentBytes([]byte("a--b")) }, `may not contain '--'`},
+		{CData{}, func(w *Writer) error { return w.WriteCDataContentBytes([]byte("a]]>b")) }, `may not contain ']]>'`},
+		{CData{}, func(w *Writer) error { return w.WriteCDataContentBytes([]byte("a\x00b")) }, `invalid chars at position 1`},
+		{Elem{Name: "foo"}, func(w *Writer) error { return w.WriteAttrBytes("a b", nil) }, `invalid`},
+		{Comment{}, func(w *Writer) error { return w.WriteTextBytes([]byte("a")) }, `unexpected kind`},
+	} {
+		_, w := open()
+		tt.OK(t, w.Start(tc.start))
+		tt.Pattern(t, tc.err, tc.write(w).Error())
+	}
+}
+
+func TestAllocsBytes(t *testing.T) {
+	ec := &ErrCollector{}
+	w := Open(ioutil.Discard)
+	text := []byte("a < b & c")
+
+	_ = allocs()
+
+	before := allocs()
+	ec.Must(w.StartElem(Elem{Name: "foo"}))
+	for i := 0; i < 100; i++ {
+		ec.Must(
+			w.StartElem(Elem{Name: "v"}),
+			w.WriteAttrBytes("a", text),
+			w.WriteTextBytes(text),
+			w.EndElem(),
+		)
+	}
+	ec.Must(w.EndAll())
+	after := allocs()
+	tt.Equals(t, uint64(0), after-before)
+	w.Flush()
+}
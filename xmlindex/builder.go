@@ -0,0 +1,237 @@
+/*
+Package xmlindex builds an index of the byte offsets of elements in a
+document while it is being written by an xmlwriter.Writer, so that readers
+of very large documents can seek straight to the elements they want.
+
+A Builder is added to the Writer as a Listener. Each element whose path
+matches the Builder's predicate is recorded in the index once it has ended:
+
+	f, _ := os.Create("catalog.xml")
+	idx, _ := os.Create("catalog.xml.idx")
+	b := xmlindex.NewBuilder(idx, xmlindex.MatchPath("/catalog/item"))
+	w := xmlwriter.Open(f, xmlwriter.WithListener(b))
+	// ... write the document ...
+	err := w.EndAllFlush()
+	err = b.Close()
+
+The binary index can then be opened with NewReader, and any record read or
+turned into an io.SectionReader over the document without reading the
+records before it.
+
+Only UTF-8 documents can be indexed. If the Writer was opened by
+OpenEncoding with any other encoding, the Builder fails the first write,
+see Writer.Pos.
+*/
+package xmlindex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/shabbyrobe/xmlwriter"
+)
+
+// Format of the index written by a Builder.
+type Format int
+
+const (
+	// Binary indexes contain fixed-size records, which can be read at
+	// random by a Reader. See NewReader for the layout.
+	Binary Format = iota
+
+	// XML indexes contain an <index> element with an <entry path="..."
+	// start="..." end="..."/> element for each record. They are easier to
+	// inspect, but have to be read from the start, see ReadXML.
+	XML
+)
+
+const (
+	magic      = "XWIX"
+	version    = 1
+	headerSize = 8
+	recordSize = 20
+	footerSize = 8
+)
+
+// Entry is the extent of an element in a document. End is the offset just
+// after the element's end tag.
+type Entry struct {
+	Path  string
+	Start int64
+	End   int64
+}
+
+// MatchPath returns a predicate for NewBuilder which matches elements with
+// exactly the given path, like "/catalog/item". Names in the path are
+// qualified with the prefix the element was written with, if any.
+func MatchPath(path string) func(path string) bool {
+	return func(p string) bool { return p == path }
+}
+
+// Builder writes an index of the elements of a document which match a
+// predicate. It is a Listener, and must be added to the Writer before
+// anything is written.
+type Builder struct {
+	// Format of the index. Defaults to Binary. Must not be changed once
+	// anything has been written.
+	Format Format
+
+	out     *bufio.Writer
+	match   func(path string) bool
+	started bool
+	closed  bool
+	err     error
+
+	path  []byte
+	stack []level
+
+	// Binary format
+	paths     map[string]uint32
+	pathList  []string
+	written   int64
+	recordBuf [recordSize]byte
+
+	// XML format
+	xw *xmlwriter.Writer
+}
+
+type level struct {
+	pathLen int
+	start   int64
+	match   bool
+}
+
+// NewBuilder creates a Builder which writes an index of the elements whose
+// path matches to out.
+func NewBuilder(out io.Writer, match func(path string) bool) *Builder {
+	return &Builder{
+		out:   bufio.NewWriter(out),
+		match: match,
+		paths: make(map[string]uint32),
+	}
+}
+
+// Event satisfies the xmlwriter.Listener interface.
+func (b *Builder) Event(w *xmlwriter.Writer, ev xmlwriter.Event) error {
+	if b.err != nil {
+		return b.err
+	}
+	// Every node is checked, so a document which can't be indexed fails
+	// as soon as it is started:
+	pos, err := w.Pos()
+	if err != nil {
+		b.err = fmt.Errorf("xmlindex: can't index document: %v", err)
+		return b.err
+	}
+	if ev.Node != xmlwriter.ElemNode {
+		return nil
+	}
+	switch ev.State {
+	case xmlwriter.StateOpen:
+		lvl := level{pathLen: len(b.path), start: pos.Offset}
+		b.path = append(b.path, '/')
+		if ev.Prefix != "" {
+			b.path = append(b.path, ev.Prefix...)
+			b.path = append(b.path, ':')
+		}
+		b.path = append(b.path, ev.Name...)
+		lvl.match = b.match(string(b.path))
+		b.stack = append(b.stack, lvl)
+
+	case xmlwriter.StateEnded:
+		last := len(b.stack) - 1
+		lvl := b.stack[last]
+		if lvl.match {
+			b.err = b.add(Entry{Path: string(b.path), Start: lvl.start, End: pos.Offset})
+		}
+		b.path = b.path[:lvl.pathLen]
+		b.stack = b.stack[:last]
+	}
+	return b.err
+}
+
+func (b *Builder) start() error {
+	b.started = true
+	if b.Format == XML {
+		b.xw = xmlwriter.Open(b.out)
+		return b.xw.StartElem(xmlwriter.Elem{Name: "index"})
+	}
+	var hdr [headerSize]byte
+	copy(hdr[:], magic)
+	hdr[4] = version
+	_, err := b.out.Write(hdr[:])
+	b.written += headerSize
+	return err
+}
+
+func (b *Builder) add(e Entry) error {
+	if b.closed {
+		return fmt.Errorf("xmlindex: builder is closed")
+	}
+	if !b.started {
+		if err := b.start(); err != nil {
+			return err
+		}
+	}
+	if b.Format == XML {
+		return b.xw.WriteElem(xmlwriter.Elem{Name: "entry", Attrs: []xmlwriter.Attr{
+			{Name: "path", Value: e.Path},
+			{Name: "start", Value: strconv.FormatInt(e.Start, 10)},
+			{Name: "end", Value: strconv.FormatInt(e.End, 10)},
+		}})
+	}
+
+	id, ok := b.paths[e.Path]
+	if !ok {
+		id = uint32(len(b.pathList))
+		b.paths[e.Path] = id
+		b.pathList = append(b.pathList, e.Path)
+	}
+	rec := b.recordBuf[:]
+	binary.BigEndian.PutUint64(rec[0:], uint64(e.Start))
+	binary.BigEndian.PutUint64(rec[8:], uint64(e.End))
+	binary.BigEndian.PutUint32(rec[16:], id)
+	_, err := b.out.Write(rec)
+	b.written += recordSize
+	return err
+}
+
+// Close finishes the index and flushes it to the io.Writer passed to
+// NewBuilder. It does not close the io.Writer.
+func (b *Builder) Close() error {
+	if b.err != nil {
+		return b.err
+	}
+	if b.closed {
+		return nil
+	}
+	if !b.started {
+		if err := b.start(); err != nil {
+			return err
+		}
+	}
+	b.closed = true
+
+	if b.Format == XML {
+		if err := b.xw.EndAllFlush(); err != nil {
+			return err
+		}
+		return b.out.Flush()
+	}
+
+	tableOffset := b.written
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(b.pathList)))
+	b.out.Write(buf[:n])
+	for _, p := range b.pathList {
+		n = binary.PutUvarint(buf[:], uint64(len(p)))
+		b.out.Write(buf[:n])
+		b.out.WriteString(p)
+	}
+	binary.BigEndian.PutUint64(buf[:], uint64(tableOffset))
+	b.out.Write(buf[:footerSize])
+	return b.out.Flush()
+}
@@ -0,0 +1,101 @@
+package xmlindex
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/shabbyrobe/xmlwriter"
+	tt "github.com/shabbyrobe/xmlwriter/testtool"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func writeCatalog(t *testing.T, b *Builder, items int) []byte {
+	t.Helper()
+	var doc bytes.Buffer
+	w := xmlwriter.Open(&doc, xmlwriter.WithIndent(), xmlwriter.WithListener(b))
+	ec := &xmlwriter.ErrCollector{}
+	ec.Must(w.Start(xmlwriter.Doc{}), w.Start(xmlwriter.Elem{Name: "catalog"}))
+	for i := 0; i < items; i++ {
+		ec.Must(w.WriteElem(xmlwriter.Elem{
+			Name:    "item",
+			Attrs:   []xmlwriter.Attr{{Name: "id", Value: fmt.Sprint(i)}},
+			Content: []xmlwriter.Writable{xmlwriter.Elem{Name: "item"}},
+		}))
+	}
+	ec.Must(w.EndAllFlush(), b.Close())
+	return doc.Bytes()
+}
+
+func TestBinary(t *testing.T) {
+	var idx bytes.Buffer
+	doc := writeCatalog(t, NewBuilder(&idx, MatchPath("/catalog/item")), 100)
+
+	r, err := NewReader(bytes.NewReader(idx.Bytes()), int64(idx.Len()))
+	tt.OK(t, err)
+	tt.Equals(t, 100, r.Len())
+
+	e, err := r.Entry(42)
+	tt.OK(t, err)
+	tt.Equals(t, "/catalog/item", e.Path)
+
+	sr, err := r.Section(bytes.NewReader(doc), 42)
+	tt.OK(t, err)
+	elem, err := ioutil.ReadAll(sr)
+	tt.OK(t, err)
+	tt.Equals(t, "<item id=\"42\"><item/></item>", string(elem))
+
+	_, err = r.Entry(100)
+	tt.Pattern(t, `out of range`, err.Error())
+}
+
+func TestBinaryManyPaths(t *testing.T) {
+	var idx bytes.Buffer
+	b := NewBuilder(&idx, func(path string) bool { return path != "/catalog" })
+	writeCatalog(t, b, 3)
+
+	r, err := NewReader(bytes.NewReader(idx.Bytes()), int64(idx.Len()))
+	tt.OK(t, err)
+	tt.Equals(t, 6, r.Len())
+	var paths []string
+	for i := 0; i < r.Len(); i++ {
+		e, err := r.Entry(i)
+		tt.OK(t, err)
+		paths = append(paths, e.Path)
+	}
+	// Entries are recorded when elements end, so children come first:
+	tt.Equals(t, []string{"/catalog/item/item", "/catalog/item"}, paths[:2])
+}
+
+func TestXML(t *testing.T) {
+	var idx bytes.Buffer
+	b := NewBuilder(&idx, MatchPath("/catalog/item"))
+	b.Format = XML
+	doc := writeCatalog(t, b, 2)
+
+	entries, err := ReadXML(&idx)
+	tt.OK(t, err)
+	tt.Equals(t, 2, len(entries))
+	e := entries[1]
+	tt.Equals(t, "<item id=\"1\"><item/></item>", string(doc[e.Start:e.End]))
+}
+
+func TestBuilderEncoding(t *testing.T) {
+	var idx bytes.Buffer
+	b := NewBuilder(&idx, MatchPath("/catalog"))
+	enc := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM).NewEncoder()
+	w := xmlwriter.OpenEncoding(&bytes.Buffer{}, "utf-16be", enc, xmlwriter.WithListener(b))
+	tt.Pattern(t, `can't index document: .* "utf-16be"`, w.Start(xmlwriter.Doc{}).Error())
+	tt.Pattern(t, `can't index document`, b.Close().Error())
+	tt.Equals(t, 0, idx.Len())
+}
+
+func TestReaderErrors(t *testing.T) {
+	_, err := NewReader(bytes.NewReader([]byte("nope")), 4)
+	tt.Pattern(t, `too short`, err.Error())
+
+	bad := []byte("XXXX\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x08")
+	_, err = NewReader(bytes.NewReader(bad), int64(len(bad)))
+	tt.Pattern(t, `not an index`, err.Error())
+}
@@ -0,0 +1,127 @@
+package xmlindex
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Reader reads records from a Binary index at random.
+//
+// A Binary index starts with an 8 byte header: the magic string "XWIX",
+// a version byte and 3 reserved bytes. The records follow, 20 bytes each:
+// the start and end offsets as big-endian uint64s, then a big-endian uint32
+// index into the path table. The path table is a uvarint count, followed
+// by each path as a uvarint length and its bytes. The index ends with the
+// offset of the path table as a big-endian uint64.
+type Reader struct {
+	r     io.ReaderAt
+	n     int
+	paths []string
+}
+
+// NewReader reads the header and path table of the Binary index in r,
+// which is size bytes long.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < headerSize+footerSize {
+		return nil, fmt.Errorf("xmlindex: index too short")
+	}
+	var hdr [headerSize]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+	if string(hdr[:4]) != magic {
+		return nil, fmt.Errorf("xmlindex: not an index")
+	}
+	if hdr[4] != version {
+		return nil, fmt.Errorf("xmlindex: unsupported index version %d", hdr[4])
+	}
+
+	var ftr [footerSize]byte
+	if _, err := r.ReadAt(ftr[:], size-footerSize); err != nil {
+		return nil, err
+	}
+	tableOffset := int64(binary.BigEndian.Uint64(ftr[:]))
+	if tableOffset < headerSize || tableOffset > size-footerSize ||
+		(tableOffset-headerSize)%recordSize != 0 {
+		return nil, fmt.Errorf("xmlindex: corrupt index footer")
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(r, tableOffset, size-footerSize-tableOffset))
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, fmt.Errorf("xmlindex: corrupt path table: %v", err)
+	}
+	paths := make([]string, 0, count)
+	for i := uint64(0); i < count; i++ {
+		n, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, fmt.Errorf("xmlindex: corrupt path table: %v", err)
+		}
+		p := make([]byte, n)
+		if _, err := io.ReadFull(br, p); err != nil {
+			return nil, fmt.Errorf("xmlindex: corrupt path table: %v", err)
+		}
+		paths = append(paths, string(p))
+	}
+
+	return &Reader{
+		r:     r,
+		n:     int((tableOffset - headerSize) / recordSize),
+		paths: paths,
+	}, nil
+}
+
+// Len returns the number of records in the index.
+func (r *Reader) Len() int { return r.n }
+
+// Entry reads record i.
+func (r *Reader) Entry(i int) (Entry, error) {
+	if i < 0 || i >= r.n {
+		return Entry{}, fmt.Errorf("xmlindex: record %d out of range", i)
+	}
+	var rec [recordSize]byte
+	if _, err := r.r.ReadAt(rec[:], headerSize+int64(i)*recordSize); err != nil {
+		return Entry{}, err
+	}
+	id := binary.BigEndian.Uint32(rec[16:])
+	if int(id) >= len(r.paths) {
+		return Entry{}, fmt.Errorf("xmlindex: record %d has unknown path %d", i, id)
+	}
+	return Entry{
+		Path:  r.paths[id],
+		Start: int64(binary.BigEndian.Uint64(rec[0:])),
+		End:   int64(binary.BigEndian.Uint64(rec[8:])),
+	}, nil
+}
+
+// Section returns a reader for the element in record i of the document the
+// index was built for.
+func (r *Reader) Section(doc io.ReaderAt, i int) (*io.SectionReader, error) {
+	e, err := r.Entry(i)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(doc, e.Start, e.End-e.Start), nil
+}
+
+// ReadXML reads all of the entries from an XML index.
+func ReadXML(r io.Reader) ([]Entry, error) {
+	var doc struct {
+		Entries []struct {
+			Path  string `xml:"path,attr"`
+			Start int64  `xml:"start,attr"`
+			End   int64  `xml:"end,attr"`
+		} `xml:"entry"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, len(doc.Entries))
+	for i, e := range doc.Entries {
+		entries[i] = Entry{Path: e.Path, Start: e.Start, End: e.End}
+	}
+	return entries, nil
+}